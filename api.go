@@ -1,27 +1,48 @@
 package main
 
 import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"math"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	docs "github.com/phllpmcphrsn/KaggleCarAPI/docs"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
+	"golang.org/x/exp/slices"
 	log "golang.org/x/exp/slog"
 )
 
 const basePath = "/api/v1"
 
+// currencyRegex matches ISO 4217 currency codes, in any case
+var currencyRegex = regexp.MustCompile(`^[A-Za-z]{3}$`)
+
 // APIServer is a gin RESTful API that will handle incoming requests for Cars.
 type APIServer struct {
 	db         CarDB
 	listenAddr string
 	basePath   string
 	env        string
+	adminToken string
+	// how uploaded files are read and how long their import may take
+	csv     CSVConfig
+	imports *importJobs
 }
 
 func NewAPIServer(db CarDB, config APIConfig, env string) *APIServer {
@@ -30,6 +51,8 @@ func NewAPIServer(db CarDB, config APIConfig, env string) *APIServer {
 		listenAddr: config.Address,
 		basePath:   config.Path,
 		env:        env,
+		adminToken: config.AdminToken,
+		imports:    newImportJobs(),
 	}
 }
 
@@ -46,7 +69,6 @@ func (a *APIServer) ping(c *gin.Context) {
 	c.JSON(http.StatusOK, "PONG")
 }
 
-// TODO add pagination (and filters/sorting?)
 // GET endpoints/methods
 
 // GetCars godoc
@@ -56,7 +78,24 @@ func (a *APIServer) ping(c *gin.Context) {
 //	@Tags			cars
 //	@Accept			json
 //	@Produce		json
-//	@Success		200	{array}	Car	"ok"
+//	@Param			fuel_economy_unit	query		string			false	"unit for city/highway/combined fuel economy (L/100km, mpg or kmpl)"	default(L/100km)
+//	@Param			drivetrain			query		string			false	"only cars offered with this drivetrain (FWD, RWD, AWD or 4WD)"
+//	@Param			transmission		query		string			false	"only cars offered with this kind of transmission (manual, automatic, DCT or CVT)"
+//	@Param			company				query		string			false	"only cars of this company"
+//	@Param			model				query		string			false	"only cars whose model contains this"
+//	@Param			body_type			query		string			false	"only cars having this body style"
+//	@Param			year				query		int				false	"only cars in production during this year, or from/until one with year[gte]/year[lte]"
+//	@Param			sort				query		string			false	"fields to sort by, separated by commas and prefixed with - to sort in descending order (id, company, model, year, price, horsepower, torque or createdAt)"
+//	@Param			cursor				query		string			false	"cursor of the page to list, given instead of a page number (empty for the first page), in which case the cars are wrapped along with the cursors of the next and previous pages"
+//	@Param			currency			query		string			false	"only cars priced in this currency (ISO 4217 code), required when comparing prices"
+//	@Param			price[lte]			query		number			false	"only cars compared to a price in the given currency (also price[eq], price[lt], price[gt] and price[gte]), likewise for horsepower, torque (Nm) and year"
+//	@Param			version				query		int				false	"cars as they were at this dataset version"
+//	@Param			envelope			query		bool			false	"wrap the cars in an object along with the page, per page, total pages and total (not with cursor)"
+//	@Success		200					{array}		Car				"ok"
+//	@Header			200					{string}	Link			"links to the first, previous, next and last pages (no last page with cursor)"
+//	@Header			200					{int}		X-Total-Count	"number of cars matching the filters"
+//	@Failure		400					{object}	map[string]any
+//	@Failure		404					{object}	map[string]any
 //	@Router			/cars/{page} [get]
 func (a *APIServer) getCars(c *gin.Context) {
 	// Following Github pagination style
@@ -75,23 +114,125 @@ func (a *APIServer) getCars(c *gin.Context) {
 		return
 	}
 
-	var count int
-	count, err = a.db.Count()
+	envelope, err := strconv.ParseBool(c.DefaultQuery("envelope", "false"))
 	if err != nil {
-		log.Error("There was an issue retriving the count of cars from DB", "err", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+		log.Error("Bad request. Could not convert envelope parameter to boolean", "err", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid envelope given. Double-check that true or false is given."})
+		return
+	}
+
+	economyUnit, err := fuelEconomyUnit(c)
+	if err != nil {
+		log.Error("Bad request. Unsupported fuel economy unit", "err", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": invalidFuelEconomyUnitMessage})
+		return
+	}
+
+	filter := &CarFilter{}
+	if drivetrain := c.Query("drivetrain"); drivetrain != "" {
+		var ok bool
+		if filter.Drivetrain, ok = drivetrainAliases[strings.ToLower(drivetrain)]; !ok {
+			log.Error("Bad request. Unrecognized drivetrain", "drivetrain", drivetrain)
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid drivetrain given. Supported drivetrains are FWD, RWD, AWD and 4WD."})
+			return
+		}
+	}
+	if transmission := c.Query("transmission"); transmission != "" {
+		for _, kind := range transmissionKinds {
+			if strings.EqualFold(kind, transmission) {
+				filter.Transmission = kind
+			}
+		}
+		if filter.Transmission == "" {
+			log.Error("Bad request. Unrecognized transmission", "transmission", transmission)
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid transmission given. Supported transmissions are manual, automatic, DCT and CVT."})
+			return
+		}
+	}
+
+	if bodyType := c.Query("body_type"); bodyType != "" {
+		style := bodyStyle(bodyType)
+		if style == nil {
+			log.Error("Bad request. Unrecognized body type", "bodyType", bodyType)
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid body type given. Supported body types are " + strings.Join(bodyStyleSlugs(), ", ") + "."})
+			return
+		}
+		filter.BodyType = style.Slug
+	}
+	filter.Company = strings.TrimSpace(c.Query("company"))
+	filter.Model = strings.TrimSpace(c.Query("model"))
+
+	if year := c.Query("year"); year != "" {
+		if filter.Year, err = strconv.Atoi(year); err != nil {
+			log.Error("Bad request. Could not convert year parameter to integer", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid year given. Double-check that a number is given."})
+			return
+		}
+	}
+
+	if filter.Ranges, err = rangeFilters(requestURL(c).Query()); err != nil {
+		log.Error("Bad request. Invalid range filter", "err", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid filter given: " + err.Error() + "."})
+		return
+	}
+
+	if currency := strings.TrimSpace(c.Query("currency")); currency != "" {
+		if !currencyRegex.MatchString(currency) {
+			log.Error("Bad request. Invalid currency", "currency", currency)
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid currency given. Double-check that a three letter ISO 4217 code is given."})
+			return
+		}
+		filter.Currency = strings.ToUpper(currency)
+	}
+	// prices in different currencies can't be compared with the same value
+	if filter.Currency == "" && slices.ContainsFunc(filter.Ranges, func(r RangeFilter) bool { return r.Field == "price" }) {
+		log.Error("Bad request. Price range given without a currency")
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid filter given: price can only be compared along with a currency, as in currency=USD."})
+		return
+	}
+
+	version, ok := a.datasetVersion(c)
+	if !ok {
+		return
+	}
+	if version != nil {
+		filter.Version = version.ID
+	}
+
+	order, err := sortFields(c.Query("sort"))
+	if err != nil {
+		log.Error("Bad request. Invalid sort", "err", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid sort given: " + err.Error() + "."})
 		return
 	}
 
 	// TODO maybe make the perpage default configurable
 	perPageStr := c.DefaultQuery("per_page", "25")
 	perPage, err := strconv.Atoi(perPageStr)
-	if err != nil {
-		log.Error("Bad request. Could not convert page parameter to integer", "err", err)
+	if err != nil || perPage < 1 {
+		log.Error("Bad request. Could not convert per_page parameter to a positive integer", "perPage", perPageStr)
 		c.AbortWithStatus((http.StatusBadRequest))
 		return
 	}
 
+	// cursors are given instead of pages
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		if envelope {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid envelope given. Cars listed from a cursor are already wrapped along with the cursors."})
+			return
+		}
+		a.getCarsFromCursor(c, cursor, filter, order, perPage, economyUnit)
+		return
+	}
+
+	// the count is of the cars matching the filter so pages only go as far as they do
+	count, err := a.db.Count(c, filter)
+	if err != nil {
+		log.Error("There was an issue retriving the count of cars from DB", "err", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
 	// Checking validity here
 	// Want to ensure we're not given a page number that doesn't exist
 	// or is too large
@@ -106,12 +247,142 @@ func (a *APIServer) getCars(c *gin.Context) {
 	}
 
 	offset := (page - 1) * perPage
-	cars, err = a.db.GetCars(c, &Pagination{Limit: uint(perPage), Offset: uint(offset)})
+	cars, err := a.db.GetCars(c, &Pagination{Limit: uint(perPage), Offset: uint(offset), Sort: order}, filter)
 	if err != nil {
 		log.Error("There was an issue retrieving rows of Cars", "err", err)
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
+
+	for _, car := range cars {
+		car.ConvertFuelEconomy(economyUnit)
+	}
+
+	links := []pageLink{{rel: "first", page: 1}}
+	if page > 1 {
+		links = append(links, pageLink{rel: "prev", page: page - 1})
+	}
+	if page < pageCount {
+		links = append(links, pageLink{rel: "next", page: page + 1})
+	}
+	links = append(links, pageLink{rel: "last", page: pageCount})
+	c.Header("Link", linkHeader(requestURL(c), links))
+	c.Header("X-Total-Count", strconv.Itoa(count))
+
+	if envelope {
+		c.IndentedJSON(http.StatusOK, CarsEnvelope{Data: cars, Page: page, PerPage: perPage, TotalPages: pageCount, Total: count})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, cars)
+}
+
+// getCarsFromCursor responds with the page of cars after the cursor (or before it), along with
+// the cursors of the next and previous pages when there are any. An empty cursor starts from
+// the first car. The sort of the listing is kept in the cursor
+func (a *APIServer) getCarsFromCursor(c *gin.Context, cursorStr string, filter *CarFilter, order []SortField, perPage int, economyUnit string) {
+	var cursor *Cursor
+	if cursorStr != "" {
+		var err error
+		if cursor, err = decodeCursor(cursorStr); err != nil {
+			log.Error("Bad request. Invalid cursor", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid cursor given."})
+			return
+		}
+		if c.Query("sort") != "" && !slices.Equal(sortKeys(order), sortKeys(cursor.Sort)) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "The cursor given is for another sort."})
+			return
+		}
+		order = cursor.Sort
+	}
+	backwards := cursor != nil && cursor.Before
+
+	count, err := a.db.Count(c, filter)
+	if err != nil {
+		log.Error("There was an issue retriving the count of cars from DB", "err", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	// one more car is asked for to know if there's a page past this one
+	cars, err := a.db.GetCars(c, &Pagination{Limit: uint(perPage) + 1, Sort: order, Cursor: cursor}, filter)
+	if err != nil {
+		log.Error("There was an issue retrieving rows of Cars", "err", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	more := len(cars) > perPage
+	if more && backwards {
+		cars = cars[1:]
+	} else if more {
+		cars = cars[:perPage]
+	}
+
+	// going one way, there's always a page back the other way
+	page := CarPage{Data: cars}
+	if len(cars) > 0 {
+		if more || backwards {
+			page.Next = newCursor(cars[len(cars)-1], order, false).String()
+		}
+		if more && backwards || cursor != nil && !backwards {
+			page.Prev = newCursor(cars[0], order, true).String()
+		}
+	}
+
+	// the last page has no cursor of its own, there's only the way back from the end
+	links := []pageLink{{rel: "first"}}
+	if page.Prev != "" {
+		links = append(links, pageLink{rel: "prev", cursor: page.Prev})
+	}
+	if page.Next != "" {
+		links = append(links, pageLink{rel: "next", cursor: page.Next})
+	}
+	// the first page's empty cursor doesn't carry the sort, so the links give it
+	linkURL := *requestURL(c)
+	if len(order) > 0 {
+		query := linkURL.Query()
+		query.Set("sort", sortParam(order))
+		linkURL.RawQuery = query.Encode()
+	}
+	c.Header("Link", linkHeader(&linkURL, links))
+	c.Header("X-Total-Count", strconv.Itoa(count))
+
+	for _, car := range cars {
+		car.ConvertFuelEconomy(economyUnit)
+	}
+	c.IndentedJSON(http.StatusOK, page)
+}
+
+// pageLink is a link to another page of a listing, by page number or, when there's none, by
+// cursor (the first page's being empty)
+type pageLink struct {
+	rel    string
+	page   int
+	cursor string
+}
+
+// linkHeader builds a Link header (RFC 8288) with the links to other pages, in the style of
+// GitHub's. The links are the request's URL with its page or cursor replaced
+func linkHeader(requestURL *url.URL, links []pageLink) string {
+	values := make([]string, len(links))
+	for i, link := range links {
+		query := requestURL.Query()
+		if link.page == 0 {
+			query.Set("cursor", link.cursor)
+		} else {
+			query.Set("page", strconv.Itoa(link.page))
+		}
+		target := url.URL{Path: requestURL.Path, RawQuery: query.Encode()}
+		values[i] = fmt.Sprintf("<%s>; rel=%q", target.String(), link.rel)
+	}
+	return strings.Join(values, ", ")
+}
+
+// requestURL returns the URL of the request, or an empty one for contexts made without one
+func requestURL(c *gin.Context) *url.URL {
+	if c.Request == nil || c.Request.URL == nil {
+		return &url.URL{}
+	}
+	return c.Request.URL
 }
 
 // GetCarById godoc
@@ -121,20 +392,207 @@ func (a *APIServer) getCars(c *gin.Context) {
 //	@Tags			cars
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		string	true	"search by id"
-//	@Success		200	{object}	Car		"ok"
+//	@Param			id					path		string	true	"search by id"
+//	@Param			fuel_economy_unit	query		string	false	"unit for city/highway/combined fuel economy (L/100km, mpg or kmpl)"	default(L/100km)
+//	@Param			version				query		int		false	"the car as it was at this dataset version"
+//	@Success		200					{object}	Car		"ok"
+//	@Failure		400					{object}	map[string]any
+//	@Failure		404					{object}	map[string]any
 //	@Router			/cars/{id} [get]
 func (a *APIServer) getCarById(c *gin.Context) {
+	economyUnit, err := fuelEconomyUnit(c)
+	if err != nil {
+		log.Error("Bad request. Unsupported fuel economy unit", "err", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": invalidFuelEconomyUnitMessage})
+		return
+	}
+
+	version, ok := a.datasetVersion(c)
+	if !ok {
+		return
+	}
+	var versionID int
+	if version != nil {
+		versionID = version.ID
+	}
+
 	id := c.Param("id")
-	car, err := a.db.GetCarById(c, id)
+	car, err := a.db.GetCarById(c, id, versionID)
 	if err != nil {
 		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Car not found."})
 		log.Error("Car not found", "err", err)
 		return
 	}
+	car.ConvertFuelEconomy(economyUnit)
 	c.IndentedJSON(http.StatusOK, car)
 }
 
+// GetCarQuality godoc
+//
+//	@Summary		Get data-quality report of a car
+//	@Description	Returns the cleaned cylinder and door counts of the car with the given id along with the data-quality warnings found while cleaning it
+//	@Tags			cars
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string		true	"search by id"
+//	@Success		200	{object}	CarQuality	"ok"
+//	@Failure		404	{object}	map[string]any
+//	@Router			/cars/{id}/quality [get]
+func (a *APIServer) getCarQuality(c *gin.Context) {
+	id := c.Param("id")
+	car, err := a.db.GetCarById(c, id, 0)
+	if err != nil {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Car not found."})
+		log.Error("Car not found", "err", err)
+		return
+	}
+
+	quality := CarQuality{
+		ID:                car.ID,
+		NumberOfDoors:     car.NumberOfDoors,
+		Doors:             car.Doors,
+		NumberofCylinders: car.NumberofCylinders,
+		Cylinders:         car.Cylinders,
+		Warnings:          car.Warnings,
+	}
+	// always respond with a list so clients don't have to tell null from no warnings
+	if quality.Warnings == nil {
+		quality.Warnings = Issues{}
+	}
+	c.IndentedJSON(http.StatusOK, quality)
+}
+
+// GetVersions godoc
+//
+//	@Summary		Get dataset versions
+//	@Description	Responds with the list of dataset versions, one for each import and for each car written through the API (with api as their source), along with how many cars they have and what changed
+//	@Tags			versions
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}		DatasetVersion	"ok"
+//	@Failure		500	{object}	map[string]any
+//	@Router			/versions [get]
+func (a *APIServer) getVersions(c *gin.Context) {
+	versions, err := a.db.GetVersions(c)
+	if err != nil {
+		log.Error("There was an issue retrieving dataset versions", "err", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, versions)
+}
+
+// datasetVersion returns the dataset version given by the version query parameter, or nil
+// when there's none. Responds with an error and returns false when it's invalid or unknown
+func (a *APIServer) datasetVersion(c *gin.Context) (*DatasetVersion, bool) {
+	versionStr := c.Query("version")
+	if versionStr == "" {
+		return nil, true
+	}
+
+	id, err := strconv.Atoi(versionStr)
+	if err != nil || id < 1 {
+		log.Error("Bad request. Invalid version parameter", "version", versionStr)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid version given. Double-check that a positive number is given."})
+		return nil, false
+	}
+
+	version, err := a.db.GetVersion(c, id)
+	if err != nil {
+		log.Error("Dataset version not found", "err", err)
+		c.JSON(http.StatusNotFound, gin.H{"message": "Version not found."})
+		return nil, false
+	}
+	return version, true
+}
+
+// GetManufacturers godoc
+//
+//	@Summary		Get Manufacturers array
+//	@Description	Responds with the list of all manufacturers, along with their aliases, parent group and country, as JSON
+//	@Tags			manufacturers
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}		Manufacturer	"ok"
+//	@Failure		500	{object}	map[string]any
+//	@Router			/manufacturers [get]
+func (a *APIServer) getManufacturers(c *gin.Context) {
+	manufacturers, err := a.db.GetManufacturers(c)
+	if err != nil {
+		log.Error("There was an issue retrieving manufacturers", "err", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, manufacturers)
+}
+
+// GetManufacturerCars godoc
+//
+//	@Summary		Get Cars of a manufacturer
+//	@Description	Responds with the list of all cars made by the manufacturer with the given id
+//	@Tags			manufacturers
+//	@Accept			json
+//	@Produce		json
+//	@Param			id					path		string	true	"manufacturer id"
+//	@Param			fuel_economy_unit	query		string	false	"unit for city/highway/combined fuel economy (L/100km, mpg or kmpl)"	default(L/100km)
+//	@Success		200					{array}		Car		"ok"
+//	@Failure		400					{object}	map[string]any
+//	@Failure		404					{object}	map[string]any
+//	@Router			/manufacturers/{id}/cars [get]
+func (a *APIServer) getManufacturerCars(c *gin.Context) {
+	economyUnit, err := fuelEconomyUnit(c)
+	if err != nil {
+		log.Error("Bad request. Unsupported fuel economy unit", "err", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": invalidFuelEconomyUnitMessage})
+		return
+	}
+
+	id := c.Param("id")
+	manufacturer, err := a.db.GetManufacturerById(c, id)
+	if err != nil {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Manufacturer not found."})
+		log.Error("Manufacturer not found", "err", err)
+		return
+	}
+
+	cars, err := a.db.GetCars(c, nil, &CarFilter{ManufacturerID: manufacturer.ID})
+	if err != nil {
+		log.Error("There was an issue retrieving rows of Cars", "err", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	for _, car := range cars {
+		car.ConvertFuelEconomy(economyUnit)
+	}
+	c.IndentedJSON(http.StatusOK, cars)
+}
+
+// GetBodyTypes godoc
+//
+//	@Summary		Get body types
+//	@Description	Responds with each body style of the vocabulary (along with its synonyms) and the number of cars having it
+//	@Tags			cars
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}		BodyTypeCount	"ok"
+//	@Failure		500	{object}	map[string]any
+//	@Router			/body-types [get]
+func (a *APIServer) getBodyTypes(c *gin.Context) {
+	counts, err := a.db.CountBodyTypes(c)
+	if err != nil {
+		log.Error("There was an issue counting the body types of cars", "err", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	bodyTypes := make([]BodyTypeCount, len(bodyStyles))
+	for i, style := range bodyStyles {
+		bodyTypes[i] = BodyTypeCount{BodyStyle: style, Count: counts[style.Slug]}
+	}
+	c.IndentedJSON(http.StatusOK, bodyTypes)
+}
+
 // POST endpoints/methods
 
 // CreateCar godoc
@@ -147,6 +605,7 @@ func (a *APIServer) getCarById(c *gin.Context) {
 //	@Param			car	body		Car	true	"Car JSON"
 //	@Success		200	{object}	Car	"ok"
 //	@Failure		400	{object}	map[string]any
+//	@Failure		409	{object}	map[string]any
 //	@Failure		500	{object}	map[string]any
 //	@Router			/cars/ [post]
 func (a *APIServer) createCar(c *gin.Context) {
@@ -161,25 +620,15 @@ func (a *APIServer) createCar(c *gin.Context) {
 		return
 	}
 
-	newCar = NewCar(
-		newCar.Company,
-		newCar.Model,
-		newCar.Horsepower,
-		newCar.Torque,
-		newCar.TransmissionType,
-		newCar.Drivetrain,
-		newCar.FuelEconomy,
-		newCar.NumberOfDoors,
-		newCar.Price,
-		newCar.BodyType,
-		newCar.EngineType,
-		newCar.NumberofCylinders,
-		newCar.StartYear,
-		newCar.EndYear,
-	)
+	newCar, ok := a.cleanCar(c, newCar)
+	if !ok {
+		return
+	}
 
-	// TODO check for duplicates. Call to DB with Car given (SELECT-statement)
-	if id, err = a.db.CreateCar(c, newCar); err != nil {
+	if id, err = a.db.CreateCar(c, newCar); errors.Is(err, errDuplicateCar) {
+		c.IndentedJSON(http.StatusConflict, gin.H{"message": "Car already exists."})
+		return
+	} else if err != nil {
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Could not insert Car into DB."})
 		log.Error("Could not insert Car into DB", "err", err)
 		return
@@ -189,7 +638,402 @@ func (a *APIServer) createCar(c *gin.Context) {
 	c.IndentedJSON(http.StatusCreated, newCar)
 }
 
+// cleanCar builds the car to store from the one given in a request, deriving its structured
+// fields from the raw values given and validating the result. Responds with an error and
+// returns false when the car is invalid
+func (a *APIServer) cleanCar(c *gin.Context, given *Car) (*Car, bool) {
+	// structured values that may be given instead of their raw counterparts
+	drivetrains := given.Drivetrains
+	transmissions := given.Transmissions
+	bodyTypes := given.BodyTypes
+
+	newCar := NewCar(
+		given.Company,
+		given.Model,
+		given.Horsepower,
+		given.Torque,
+		given.TransmissionType,
+		given.Drivetrain,
+		given.FuelEconomy,
+		given.NumberOfDoors,
+		given.Price,
+		given.BodyType,
+		given.EngineType,
+		given.NumberofCylinders,
+		given.StartYear,
+		given.EndYear,
+		given.InProduction,
+	)
+	newCar.Drivetrains = drivetrains
+	newCar.Transmissions = transmissions
+	newCar.BodyTypes = bodyTypes
+
+	// derive the structured fields from the raw values given
+	if err := clean(&CarRecord{Car: newCar}); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Invalid car given."})
+		log.Error("Could not clean Car", "err", err)
+		return nil, false
+	}
+	manufacturers, err := a.db.GetManufacturers(c)
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Could not retrieve manufacturers from DB."})
+		log.Error("Could not retrieve manufacturers from DB", "err", err)
+		return nil, false
+	}
+	newManufacturerIndex(manufacturers).resolve(newCar)
+
+	if err := newCar.Validate(); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Invalid car given: " + err.Error()})
+		log.Error("Invalid Car given", "err", err)
+		return nil, false
+	}
+	return newCar, true
+}
+
+// PUT, PATCH and DELETE endpoints/methods
+
+// ReplaceCar godoc
+//
+//	@Summary		Replace a car
+//	@Description	Takes a car JSON and replaces the car with the given id by it. Responds with the stored car
+//	@Tags			cars
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"car id"
+//	@Param			car	body		Car		true	"Car JSON"
+//	@Success		200	{object}	Car		"ok"
+//	@Failure		400	{object}	map[string]any
+//	@Failure		404	{object}	map[string]any
+//	@Failure		409	{object}	map[string]any
+//	@Failure		500	{object}	map[string]any
+//	@Router			/cars/{id} [put]
+func (a *APIServer) replaceCar(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Car not found."})
+		return
+	}
+
+	var given *Car
+	if err := c.BindJSON(&given); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Invalid car given."})
+		return
+	}
+
+	car, ok := a.cleanCar(c, given)
+	if !ok {
+		return
+	}
+	car.ID = id
+	a.storeUpdatedCar(c, car)
+}
+
+// mergePatchContentType is the media type of JSON merge patches (RFC 7386)
+const mergePatchContentType = "application/merge-patch+json"
+
+// PatchCar godoc
+//
+//	@Summary		Update fields of a car
+//	@Description	Takes a JSON merge patch (RFC 7386) and applies it to the car with the given id. Fields set to null are cleared. Responds with the stored car
+//	@Tags			cars
+//	@Accept			json
+//	@Accept			application/merge-patch+json
+//	@Produce		json
+//	@Param			id		path		string			true	"car id"
+//	@Param			patch	body		map[string]any	true	"fields to change"
+//	@Success		200		{object}	Car				"ok"
+//	@Failure		400		{object}	map[string]any
+//	@Failure		404		{object}	map[string]any
+//	@Failure		409		{object}	map[string]any
+//	@Failure		415		{object}	map[string]any
+//	@Failure		500		{object}	map[string]any
+//	@Router			/cars/{id} [patch]
+func (a *APIServer) patchCar(c *gin.Context) {
+	if contentType := c.ContentType(); contentType != mergePatchContentType && contentType != "application/json" {
+		c.IndentedJSON(http.StatusUnsupportedMediaType, gin.H{"message": "Unsupported content type, patches must be " + mergePatchContentType + "."})
+		return
+	}
+
+	existing, err := a.db.GetCarById(c, c.Param("id"), 0)
+	if err != nil {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Car not found."})
+		log.Error("Car not found", "err", err)
+		return
+	}
+
+	var patch map[string]any
+	if err := c.BindJSON(&patch); err != nil || patch == nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Invalid patch given, it must be a JSON object."})
+		return
+	}
+
+	patched, err := applyMergePatch(existing, patch)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Invalid car given."})
+		log.Error("Could not apply patch to Car", "id", existing.ID, "err", err)
+		return
+	}
+
+	car, ok := a.cleanCar(c, patched)
+	if !ok {
+		return
+	}
+	car.ID = existing.ID
+	a.storeUpdatedCar(c, car)
+}
+
+// storeUpdatedCar stores the car in place of the one with the same ID and responds with it
+func (a *APIServer) storeUpdatedCar(c *gin.Context, car *Car) {
+	err := a.db.UpdateCar(c, car)
+	if errors.Is(err, errCarNotFound) {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Car not found."})
+		return
+	} else if errors.Is(err, errDuplicateCar) {
+		c.IndentedJSON(http.StatusConflict, gin.H{"message": "Car already exists."})
+		return
+	} else if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Could not update Car in DB."})
+		log.Error("Could not update Car in DB", "id", car.ID, "err", err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, car)
+}
+
+// DeleteCar godoc
+//
+//	@Summary		Delete a car
+//	@Description	Deletes the car with the given id. It's still found at the dataset versions it was part of
+//	@Tags			cars
+//	@Param			id	path	string	true	"car id"
+//	@Success		204
+//	@Failure		404	{object}	map[string]any
+//	@Failure		500	{object}	map[string]any
+//	@Router			/cars/{id} [delete]
+func (a *APIServer) deleteCar(c *gin.Context) {
+	err := a.db.DeleteCar(c, c.Param("id"))
+	if errors.Is(err, errCarNotFound) {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Car not found."})
+		return
+	} else if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Could not delete Car from DB."})
+		log.Error("Could not delete Car from DB", "id", c.Param("id"), "err", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Admin endpoints/methods
+
+// requireAdminToken only lets requests through when they bear the admin token. The admin
+// endpoints can't be used at all until a token is configured
+func (a *APIServer) requireAdminToken(c *gin.Context) {
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if a.adminToken == "" || !found || subtle.ConstantTimeCompare([]byte(token), []byte(a.adminToken)) != 1 {
+		c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": "Invalid or missing admin token."})
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// GetRejections godoc
+//
+//	@Summary		Get rejected rows
+//	@Description	Responds with the rows of imported datasets that couldn't be cleaned or stored, along with why
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{array}		RejectedRow	"ok"
+//	@Failure		401	{object}	map[string]any
+//	@Failure		500	{object}	map[string]any
+//	@Router			/admin/rejections [get]
+func (a *APIServer) getRejections(c *gin.Context) {
+	rows, err := a.db.GetRejectedRows(c)
+	if err != nil {
+		log.Error("There was an issue retrieving rejected rows", "err", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, rows)
+}
+
+// RetryRejection godoc
+//
+//	@Summary		Retry a rejected row
+//	@Description	Cleans and stores the rejected row again, removing it from the rejections once it's stored. Fields given in the body replace the ones of the rejected record
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id		path		string		true	"rejected row id"
+//	@Param			record	body		CarRecord	false	"corrected fields of the record"
+//	@Success		201		{object}	Car			"ok"
+//	@Failure		400		{object}	map[string]any
+//	@Failure		401		{object}	map[string]any
+//	@Failure		404		{object}	map[string]any
+//	@Failure		409		{object}	map[string]any
+//	@Failure		500		{object}	map[string]any
+//	@Router			/admin/rejections/{id}/retry [post]
+func (a *APIServer) retryRejection(c *gin.Context) {
+	rejected, err := a.db.GetRejectedRowById(c, c.Param("id"))
+	if err != nil {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Rejected row not found."})
+		log.Error("Rejected row not found", "err", err)
+		return
+	}
+
+	record := &CarRecord{Car: &Car{}}
+	if err := json.Unmarshal(rejected.Record, record); err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Could not read rejected record."})
+		log.Error("Could not unmarshal rejected record", "id", rejected.ID, "err", err)
+		return
+	}
+
+	// unmarshalling the corrections onto the record only replaces the fields given
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil || len(bytes.TrimSpace(body)) > 0 && json.Unmarshal(body, record) != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Invalid corrections given."})
+		return
+	}
+
+	if err := clean(record); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Row is still invalid: " + err.Error()})
+		log.Error("Could not clean rejected row", "id", rejected.ID, "err", err)
+		return
+	}
+
+	manufacturers, err := a.db.GetManufacturers(c)
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Could not retrieve manufacturers from DB."})
+		log.Error("Could not retrieve manufacturers from DB", "err", err)
+		return
+	}
+	car := record.Car
+	newManufacturerIndex(manufacturers).resolve(car)
+	car.CreatedAt = time.Now().UTC()
+
+	id, err := a.db.CreateCar(c, car)
+	if errors.Is(err, errDuplicateCar) {
+		c.IndentedJSON(http.StatusConflict, gin.H{"message": "Car already exists."})
+		return
+	} else if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Could not insert Car into DB."})
+		log.Error("Could not insert Car into DB", "err", err)
+		return
+	}
+	car.ID = id
+
+	// the car is stored either way, so a rejection that lingers is only logged
+	if err := a.db.DeleteRejectedRow(c, rejected.ID); err != nil {
+		log.Error("Could not delete retried rejected row", "id", rejected.ID, "err", err)
+	}
+	c.IndentedJSON(http.StatusCreated, car)
+}
+
+// CreateImport godoc
+//
+//	@Summary		Import a dataset file
+//	@Description	Takes a dataset file and imports it in the background, cleaning it the same way as the dataset read at startup. Responds with the import job to poll for its progress
+//	@Tags			admin
+//	@Accept			mpfd
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			file	formData	file		true	"dataset file"
+//	@Param			format	formData	string		false	"format of the file, its extension when not given"	Enums(csv, json, ndjson, xlsx)
+//	@Success		202		{object}	ImportJob	"ok"
+//	@Failure		400		{object}	map[string]any
+//	@Failure		401		{object}	map[string]any
+//	@Failure		500		{object}	map[string]any
+//	@Router			/admin/imports [post]
+func (a *APIServer) createImport(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "No file given."})
+		log.Error("No file given", "err", err)
+		return
+	}
+
+	reader, err := recordReaderFor(c.PostForm("format"), header.Filename, a.csv)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Unsupported format given: " + err.Error()})
+		log.Error("Unsupported format given", "err", err)
+		return
+	}
+
+	// the upload is gone once the request is done so the job gets its own copy
+	file, err := saveUpload(header)
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Could not save the file."})
+		log.Error("Could not save the uploaded file", "filename", header.Filename, "err", err)
+		return
+	}
+
+	job := a.imports.add(filepath.Base(header.Filename))
+	go a.imports.run(job, file, reader, a.db, a.csv.Timeout)
+
+	job, _ = a.imports.get(job.ID)
+	c.IndentedJSON(http.StatusAccepted, job)
+}
+
+// saveUpload copies the uploaded file to a temporary one, ready to be read from
+func saveUpload(header *multipart.FileHeader) (*os.File, error) {
+	upload, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer upload.Close()
+
+	file, err := os.CreateTemp("", "import-*"+filepath.Ext(header.Filename))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(file, upload); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return file, nil
+}
+
+// GetImport godoc
+//
+//	@Summary		Get an import job
+//	@Description	Responds with the progress of an import job and, once it's done, how many cars were inserted, updated and rejected
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int			true	"import job id"
+//	@Success		200	{object}	ImportJob	"ok"
+//	@Failure		401	{object}	map[string]any
+//	@Failure		404	{object}	map[string]any
+//	@Router			/admin/imports/{id} [get]
+func (a *APIServer) getImport(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Import not found."})
+		return
+	}
+
+	job, ok := a.imports.get(id)
+	if !ok {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Import not found."})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, job)
+}
+
 func (a *APIServer) StartRouter() {
+	if a.adminToken == "" {
+		log.Warn("No admin token configured, the admin endpoints will refuse every request")
+	}
+
 	r := gin.Default()
 	if os.Getenv(gin.EnvGinMode) == "" {
 		mode := ginEnvMode(a.env)
@@ -206,13 +1050,177 @@ func (a *APIServer) StartRouter() {
 		v1.GET("/ping", a.ping)
 		v1.GET("/cars/", a.getCars)
 		v1.GET("/cars/:id", a.getCarById)
+		v1.GET("/cars/:id/quality", a.getCarQuality)
 		v1.POST("/cars", a.createCar)
+		v1.PUT("/cars/:id", a.replaceCar)
+		v1.PATCH("/cars/:id", a.patchCar)
+		v1.DELETE("/cars/:id", a.deleteCar)
+		v1.GET("/body-types", a.getBodyTypes)
+		v1.GET("/manufacturers", a.getManufacturers)
+		v1.GET("/manufacturers/:id/cars", a.getManufacturerCars)
+		v1.GET("/versions", a.getVersions)
+
+		admin := v1.Group("/admin", a.requireAdminToken)
+		admin.GET("/rejections", a.getRejections)
+		admin.POST("/rejections/:id/retry", a.retryRejection)
+		admin.POST("/imports", a.createImport)
+		admin.GET("/imports/:id", a.getImport)
 
 	}
 
 	r.Run(a.listenAddr)
 }
 
+const invalidFuelEconomyUnitMessage = "Invalid fuel_economy_unit given. Supported units are L/100km, mpg and kmpl."
+
+// fuelEconomyUnit returns the unit requested with the fuel_economy_unit query parameter.
+// Economy is stored in L/100km so that's the default
+func fuelEconomyUnit(c *gin.Context) (string, error) {
+	return ParseFuelEconomyUnit(c.DefaultQuery("fuel_economy_unit", fuelEconomyUnitL100km))
+}
+
+// sortFields parses the sort parameter, a list of fields separated by commas, each prefixed
+// with - to sort in descending order
+func sortFields(param string) ([]SortField, error) {
+	if strings.TrimSpace(param) == "" {
+		return nil, nil
+	}
+
+	var fields []SortField
+	seen := map[string]bool{}
+	for _, name := range strings.Split(param, ",") {
+		name = strings.TrimSpace(name)
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+		if !slices.Contains(sortableFields, name) {
+			return nil, fmt.Errorf("cars can't be sorted by %q, only by %s", name, strings.Join(sortableFields, ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("cars are sorted by %s more than once", name)
+		}
+		seen[name] = true
+		fields = append(fields, SortField{Field: name, Desc: desc})
+	}
+	return fields, nil
+}
+
+// sortParam formats the fields as the sort parameter sortFields parses
+func sortParam(fields []SortField) string {
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Field
+		if field.Desc {
+			names[i] = "-" + field.Field
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// rangeFilters returns the range filters among the query parameters, given as price[lte]=50000.
+// The filters are sorted so the same query always gives the same SQL
+func rangeFilters(query url.Values) ([]RangeFilter, error) {
+	var ranges []RangeFilter
+	for key, values := range query {
+		field, op, found := strings.Cut(key, "[")
+		if !found {
+			continue
+		}
+		op, found = strings.CutSuffix(op, "]")
+		if _, ok := rangeColumns[field]; !ok || !found {
+			return nil, fmt.Errorf("%s can't be compared, only price, horsepower, torque and year can", key)
+		}
+		if !slices.Contains(rangeOperators, op) {
+			return nil, fmt.Errorf("unknown operator %q of %s, must be one of %s", op, field, strings.Join(rangeOperators, ", "))
+		}
+
+		for _, value := range values {
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+				return nil, fmt.Errorf("%s must be a number", key)
+			}
+			if slices.Contains(integerRangeFields, field) && number != math.Trunc(number) {
+				return nil, fmt.Errorf("%s must be a whole number", key)
+			}
+			ranges = append(ranges, RangeFilter{Field: field, Op: op, Value: number})
+		}
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		if ranges[i].Field != ranges[j].Field {
+			return ranges[i].Field < ranges[j].Field
+		}
+		if ranges[i].Op != ranges[j].Op {
+			return ranges[i].Op < ranges[j].Op
+		}
+		return ranges[i].Value < ranges[j].Value
+	})
+	return ranges, nil
+}
+
+// patchedFields pairs the raw fields of a car with the structured ones derived from them.
+// When a patch only gives one of a pair, the other is cleared so it's derived again rather
+// than left as it was
+var patchedFields = [][2]string{
+	{"drivetrain", "drivetrains"},
+	{"transmissionType", "transmissions"},
+	{"bodyType", "bodyTypes"},
+}
+
+// applyMergePatch returns the car with the JSON merge patch (RFC 7386) applied to it
+func applyMergePatch(car *Car, patch map[string]any) (*Car, error) {
+	b, err := json.Marshal(car)
+	if err != nil {
+		return nil, err
+	}
+	var target map[string]any
+	if err := json.Unmarshal(b, &target); err != nil {
+		return nil, err
+	}
+
+	merged := mergePatch(target, patch).(map[string]any)
+	for _, fields := range patchedFields {
+		_, raw := patch[fields[0]]
+		_, structured := patch[fields[1]]
+		if raw && !structured {
+			delete(merged, fields[1])
+		} else if structured && !raw {
+			delete(merged, fields[0])
+		}
+	}
+
+	b, err = json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	patched := &Car{}
+	if err := json.Unmarshal(b, patched); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+// mergePatch applies the patch to the target as described by RFC 7386: members of the patch
+// replace the target's, objects being merged recursively, and null members are removed
+func mergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergePatch(targetObject[name], value)
+		}
+	}
+	return targetObject
+}
+
 func ginEnvMode(env string) string {
 	switch env {
 	case "prod":
@@ -3,9 +3,15 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -52,13 +58,347 @@ func TestGetCars(t *testing.T) {
 	// assert the response status and body
 	assert.Equal(t, http.StatusOK, w.Code)
 	
-	var actualBody []Car
+	var actualBody []*Car
 	err := json.Unmarshal(w.Body.Bytes(), &actualBody)
 	if assert.NoError(t, err){
 		assert.Equal(t, cars, actualBody)
 	}
 }
 
+// listCars serves a request for the car listing with the query, from an API backed by the mock DB
+func listCars(query string) *httptest.ResponseRecorder {
+	router := gin.Default()
+	api := NewAPIServer(&MockDB{}, APIConfig{}, "")
+	router.GET("/cars/", api.getCars)
+
+	req, _ := http.NewRequest("GET", "/cars/?"+query, nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// assertModels checks that the cars listed in the body are of the models expected, in order
+func assertModels(t *testing.T, expectedModels []string, body []byte) {
+	var cars []*Car
+	if assert.NoError(t, json.Unmarshal(body, &cars)) {
+		assert.Equal(t, expectedModels, carModels(cars))
+	}
+}
+
+// carModels returns the models of the cars, in order
+func carModels(cars []*Car) []string {
+	models := []string{}
+	for _, car := range cars {
+		models = append(models, car.Model)
+	}
+	return models
+}
+
+func TestGetCarsAtVersion(t *testing.T) {
+	testCases := []struct {
+		name           string
+		version        string
+		expectedStatus int
+		expectedModels []string
+	}{
+		{name: "First Version", version: "1", expectedStatus: http.StatusOK, expectedModels: []string{"Corolla"}},
+		{name: "Latest Version", version: "2", expectedStatus: http.StatusOK, expectedModels: []string{"Corolla", "F150", "Cobalt"}},
+		{name: "Unknown Version", version: "9", expectedStatus: http.StatusNotFound},
+		{name: "Invalid Version", version: "first", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := listCars("version="+tc.version)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			assertModels(t, tc.expectedModels, rec.Body.Bytes())
+		})
+	}
+}
+
+func TestGetCarsFiltered(t *testing.T) {
+	testCases := []struct {
+		name            string
+		query           string
+		expectedStatus  int
+		expectedModels  []string
+		expectedMessage string
+	}{
+		{name: "Company", query: "company=ford", expectedStatus: http.StatusOK, expectedModels: []string{"F150"}},
+		{name: "Model", query: "model=co", expectedStatus: http.StatusOK, expectedModels: []string{"Corolla", "Cobalt"}},
+		{name: "No Match", query: "company=Ferrari", expectedStatus: http.StatusOK, expectedModels: []string{}},
+		{name: "Range", query: "currency=usd&price[lte]=50000&horsepower[gt]=100", expectedStatus: http.StatusOK, expectedModels: []string{"Corolla", "F150", "Cobalt"}},
+		{name: "Price Range Without Currency", query: "price[lte]=50000", expectedStatus: http.StatusBadRequest, expectedMessage: "Invalid filter given: price can only be compared along with a currency, as in currency=USD."},
+		{name: "Invalid Currency", query: "currency=dollars&price[lte]=50000", expectedStatus: http.StatusBadRequest, expectedMessage: "Invalid currency given. Double-check that a three letter ISO 4217 code is given."},
+		{name: "Invalid Body Type", query: "body_type=tank", expectedStatus: http.StatusBadRequest, expectedMessage: "Invalid body type given. Supported body types are " + strings.Join(bodyStyleSlugs(), ", ") + "."},
+		{name: "Invalid Range Field", query: "doors[gt]=2", expectedStatus: http.StatusBadRequest, expectedMessage: "Invalid filter given: doors[gt] can't be compared, only price, horsepower, torque and year can."},
+		{name: "Invalid Range Value", query: "currency=USD&price[lte]=cheap", expectedStatus: http.StatusBadRequest, expectedMessage: "Invalid filter given: price[lte] must be a number."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := listCars(tc.query)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expectedStatus != http.StatusOK {
+				var body map[string]any
+				if assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body)) {
+					assert.Equal(t, tc.expectedMessage, body["message"])
+				}
+				return
+			}
+
+			assertModels(t, tc.expectedModels, rec.Body.Bytes())
+		})
+	}
+}
+
+func TestGetCarsSorted(t *testing.T) {
+	testCases := []struct {
+		name           string
+		sort           string
+		expectedStatus int
+		expectedModels []string
+	}{
+		{name: "Default Order", sort: "", expectedStatus: http.StatusOK, expectedModels: []string{"Corolla", "F150", "Cobalt"}},
+		{name: "Ascending", sort: "company", expectedStatus: http.StatusOK, expectedModels: []string{"Cobalt", "F150", "Corolla"}},
+		{name: "Descending", sort: "-model", expectedStatus: http.StatusOK, expectedModels: []string{"F150", "Corolla", "Cobalt"}},
+		{name: "Unknown Field", sort: "color", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := listCars("sort="+tc.sort)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			assertModels(t, tc.expectedModels, rec.Body.Bytes())
+		})
+	}
+}
+
+func TestGetCarsPagination(t *testing.T) {
+	testCases := []struct {
+		name             string
+		query            string
+		expectedStatus   int
+		expectedLink     string
+		expectedModels   []string
+		expectedEnvelope *CarsEnvelope
+	}{
+		{
+			name:           "First Page",
+			query:          "per_page=2",
+			expectedStatus: http.StatusOK,
+			expectedLink:   `</cars/?page=1&per_page=2>; rel="first", </cars/?page=2&per_page=2>; rel="next", </cars/?page=2&per_page=2>; rel="last"`,
+			expectedModels: []string{"Corolla", "F150"},
+		},
+		{
+			name:           "Middle Page",
+			query:          "per_page=1&page=2&sort=-model",
+			expectedStatus: http.StatusOK,
+			expectedLink:   `</cars/?page=1&per_page=1&sort=-model>; rel="first", </cars/?page=1&per_page=1&sort=-model>; rel="prev", </cars/?page=3&per_page=1&sort=-model>; rel="next", </cars/?page=3&per_page=1&sort=-model>; rel="last"`,
+			expectedModels: []string{"Corolla"},
+		},
+		{
+			name:             "Envelope",
+			query:            "per_page=2&page=2&envelope=true",
+			expectedStatus:   http.StatusOK,
+			expectedLink:     `</cars/?envelope=true&page=1&per_page=2>; rel="first", </cars/?envelope=true&page=1&per_page=2>; rel="prev", </cars/?envelope=true&page=2&per_page=2>; rel="last"`,
+			expectedEnvelope: &CarsEnvelope{Data: []*Car{{ID: 3, Company: "Chevrolet", Model: "Cobalt"}}, Page: 2, PerPage: 2, TotalPages: 2, Total: 3},
+		},
+		{name: "Invalid Envelope", query: "envelope=maybe", expectedStatus: http.StatusBadRequest},
+		{name: "Page Past The Last", query: "per_page=2&page=3", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid Per Page", query: "per_page=0", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := listCars(tc.query)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tc.expectedLink, rec.Header().Get("Link"))
+			assert.Equal(t, "3", rec.Header().Get("X-Total-Count"))
+
+			if tc.expectedEnvelope != nil {
+				var envelope CarsEnvelope
+				if assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &envelope)) {
+					assert.Equal(t, *tc.expectedEnvelope, envelope)
+				}
+				return
+			}
+			assertModels(t, tc.expectedModels, rec.Body.Bytes())
+		})
+	}
+}
+
+func TestGetCarsFromCursor(t *testing.T) {
+	getPage := func(query string) (int, CarPage) {
+		rec := listCars(query)
+
+		var page CarPage
+		if rec.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+			// the cursors are also given as links, along with the first page's, keeping the sort
+			assert.Regexp(t, `<[^>]*cursor=&[^>]*sort=company[^>]*>; rel="first"`, rec.Header().Get("Link"))
+			assert.Equal(t, "3", rec.Header().Get("X-Total-Count"))
+			for rel, cursor := range map[string]string{"prev": page.Prev, "next": page.Next} {
+				if cursor != "" {
+					assert.Regexp(t, fmt.Sprintf(`<[^>]*cursor=%s&[^>]*>; rel=%q`, cursor, rel), rec.Header().Get("Link"))
+				} else {
+					assert.NotContains(t, rec.Header().Get("Link"), fmt.Sprintf("rel=%q", rel))
+				}
+			}
+		}
+		return rec.Code, page
+	}
+	models := func(page CarPage) []string {
+		return carModels(page.Data)
+	}
+
+	// walking forward then back again
+	status, first := getPage("per_page=2&sort=company&cursor=")
+	if assert.Equal(t, http.StatusOK, status) {
+		assert.Equal(t, []string{"Cobalt", "F150"}, models(first))
+		assert.NotEmpty(t, first.Next)
+		assert.Empty(t, first.Prev)
+	}
+
+	status, second := getPage("per_page=2&cursor=" + first.Next)
+	if assert.Equal(t, http.StatusOK, status) {
+		assert.Equal(t, []string{"Corolla"}, models(second))
+		assert.Empty(t, second.Next)
+		assert.NotEmpty(t, second.Prev)
+	}
+
+	status, back := getPage("per_page=2&cursor=" + second.Prev)
+	if assert.Equal(t, http.StatusOK, status) {
+		assert.Equal(t, models(first), models(back))
+		assert.NotEmpty(t, back.Next)
+		assert.Empty(t, back.Prev)
+	}
+
+	status, _ = getPage("cursor=bm90IGpzb24")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = getPage("envelope=true&cursor=")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = getPage("sort=-model&cursor=" + first.Next)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestSortFields(t *testing.T) {
+	testCases := []struct {
+		name           string
+		param          string
+		expectedFields []SortField
+		expectedErr    string
+	}{
+		{name: "No Sort", param: ""},
+		{
+			name:           "Several Fields",
+			param:          "-horsepower, company",
+			expectedFields: []SortField{{Field: "horsepower", Desc: true}, {Field: "company"}},
+		},
+		{name: "Unknown Field", param: "company,color", expectedErr: `cars can't be sorted by "color", only by id, company, model, year, price, horsepower, torque, createdAt`},
+		{name: "Empty Field", param: "company,", expectedErr: `cars can't be sorted by "", only by id, company, model, year, price, horsepower, torque, createdAt`},
+		{name: "Repeated Field", param: "price,-price", expectedErr: "cars are sorted by price more than once"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fields, err := sortFields(tc.param)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expectedFields, fields)
+			}
+		})
+	}
+}
+
+func TestSortParam(t *testing.T) {
+	fields := []SortField{{Field: "horsepower", Desc: true}, {Field: "company"}}
+	assert.Equal(t, "-horsepower,company", sortParam(fields))
+
+	parsed, err := sortFields(sortParam(fields))
+	if assert.NoError(t, err) {
+		assert.Equal(t, fields, parsed)
+	}
+}
+
+func TestRangeFilters(t *testing.T) {
+	testCases := []struct {
+		name           string
+		query          string
+		expectedRanges []RangeFilter
+		expectedErr    string
+	}{
+		{name: "No Ranges", query: "company=Toyota&year=2015"},
+		{
+			name:  "Sorted Ranges",
+			query: "year[lte]=2015&price[lte]=50000&year[gte]=2010",
+			expectedRanges: []RangeFilter{
+				{Field: "price", Op: rangeLte, Value: 50000},
+				{Field: "year", Op: rangeGte, Value: 2010},
+				{Field: "year", Op: rangeLte, Value: 2015},
+			},
+		},
+		{name: "Decimal Value", query: "torque[eq]=250.5", expectedRanges: []RangeFilter{{Field: "torque", Op: rangeEq, Value: 250.5}}},
+		{name: "Unknown Field", query: "weight[lt]=1500", expectedErr: "weight[lt] can't be compared, only price, horsepower, torque and year can"},
+		{name: "Unknown Operator", query: "price[ne]=1", expectedErr: `unknown operator "ne" of price, must be one of eq, lt, lte, gt, gte`},
+		{name: "Unclosed Bracket", query: "price[lte=1", expectedErr: "price[lte can't be compared, only price, horsepower, torque and year can"},
+		{name: "Not A Number", query: "horsepower[gte]=NaN", expectedErr: "horsepower[gte] must be a number"},
+		{name: "Decimal Horsepower", query: "horsepower[gte]=150.5", expectedErr: "horsepower[gte] must be a whole number"},
+		{name: "Decimal Year", query: "year[lt]=2015.5", expectedErr: "year[lt] must be a whole number"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tc.query)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			ranges, err := rangeFilters(query)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expectedRanges, ranges)
+			}
+		})
+	}
+}
+
+func TestGetVersions(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	a := NewAPIServer(&MockDB{}, APIConfig{}, "")
+
+	a.getVersions(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var actualBody []*DatasetVersion
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &actualBody)) {
+		assert.Equal(t, versions, actualBody)
+	}
+}
+
 func TestGetCarById(t *testing.T) {
 	// Define the test cases as a table
 	testCases := []struct {
@@ -71,7 +411,7 @@ func TestGetCarById(t *testing.T) {
 			name:           "Valid Car ID",
 			carID:          "1",
 			expectedStatus: http.StatusOK,
-			expectedBody:   `{"id": 1, "company": "Toyota", "model": "Corolla", "horsepower": "", "torque": "", "transmissionType": "", "drivetrain": "", "fuelEconomy": "", "numberOfDoors": "", "price": "", "startYear": 0, "endYear": 0, "bodyType": "", "engineType": "", "numberOfCylinders": "", "createdAt": "0001-01-01T00:00:00Z"}`,
+			expectedBody:   `{"id": 1, "company": "Toyota", "manufacturerId": null, "model": "Corolla", "horsepower": "", "horsepowerMin": null, "horsepowerMax": null, "horsepowerUnit": "", "horsepowerRpmMin": null, "horsepowerRpmMax": null, "torque": "", "torqueMinNm": null, "torqueMaxNm": null, "torqueMinLbFt": null, "torqueMaxLbFt": null, "torqueRpmMin": null, "torqueRpmMax": null, "transmissionType": "", "transmissions": null, "drivetrain": "", "drivetrains": null, "fuelEconomy": "", "fuelEconomyCity": null, "fuelEconomyHighway": null, "fuelEconomyCombined": null, "fuelEconomyUnit": "", "fuelEconomySourceUnit": "", "numberOfDoors": "", "doors": null, "price": "", "priceCurrency": "", "priceMin": null, "priceMax": null, "priceIsStarting": false, "startYear": 0, "endYear": null, "inProduction": false, "bodyType": "", "bodyTypes": null, "engineType": "", "engineDisplacements": null, "engineLayouts": null, "engineAspirations": null, "engineFuels": null, "numberOfCylinders": "", "cylinders": null, "createdAt": "0001-01-01T00:00:00Z"}`,
 		},
 		{
 			name:           "Invalid Car ID",
@@ -79,6 +419,12 @@ func TestGetCarById(t *testing.T) {
 			expectedStatus: http.StatusNotFound,
 			expectedBody:   `{"message": "Car not found."}`,
 		},
+		{
+			name:           "Unknown Version",
+			carID:          "1?version=9",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"message": "Version not found."}`,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -113,6 +459,121 @@ func TestGetCarById(t *testing.T) {
 	}
 }
 
+func TestGetCarQuality(t *testing.T) {
+	testCases := []struct {
+		name           string
+		carID          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Valid Car ID",
+			carID:          "1",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id": 1, "numberOfDoors": "", "doors": null, "numberOfCylinders": "", "cylinders": null, "warnings": []}`,
+		},
+		{
+			name:           "Invalid Car ID",
+			carID:          "456",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"message": "Car not found."}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.Default()
+			api := NewAPIServer(&MockDB{}, APIConfig{}, "")
+			router.GET("/cars/:id/quality", api.getCarQuality)
+
+			req, _ := http.NewRequest("GET", "/cars/"+tc.carID+"/quality", nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+
+			var actualBody map[string]interface{}
+			var expectedBody map[string]interface{}
+			err := json.Unmarshal(rec.Body.Bytes(), &actualBody)
+			tcErr := json.Unmarshal([]byte(tc.expectedBody), &expectedBody)
+			if assert.NoError(t, err) && assert.NoError(t, tcErr) {
+				assert.Equal(t, expectedBody, actualBody)
+			}
+		})
+	}
+}
+
+func TestGetManufacturers(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	a := NewAPIServer(&MockDB{}, APIConfig{}, "")
+
+	a.getManufacturers(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var actualBody []*Manufacturer
+	err := json.Unmarshal(w.Body.Bytes(), &actualBody)
+	expectedBody, _ := (&MockDB{}).GetManufacturers(c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, expectedBody, actualBody)
+	}
+}
+
+func TestGetManufacturerCars(t *testing.T) {
+	testCases := []struct {
+		name           string
+		manufacturerID string
+		expectedStatus int
+		expectedModels []string
+	}{
+		{name: "Valid Manufacturer ID", manufacturerID: "1", expectedStatus: http.StatusOK, expectedModels: []string{"Corolla"}},
+		{name: "Manufacturer Without Cars", manufacturerID: "2", expectedStatus: http.StatusOK, expectedModels: []string{}},
+		{name: "Invalid Manufacturer ID", manufacturerID: "456", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.Default()
+			api := NewAPIServer(&MockDB{}, APIConfig{}, "")
+			router.GET("/manufacturers/:id/cars", api.getManufacturerCars)
+
+			req, _ := http.NewRequest("GET", "/manufacturers/"+tc.manufacturerID+"/cars", nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			assertModels(t, tc.expectedModels, rec.Body.Bytes())
+		})
+	}
+}
+
+func TestGetBodyTypes(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	a := NewAPIServer(&MockDB{}, APIConfig{}, "")
+
+	a.getBodyTypes(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var actualBody []BodyTypeCount
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &actualBody)) {
+		counts := map[string]int{}
+		for _, bodyType := range actualBody {
+			counts[bodyType.Slug] = bodyType.Count
+		}
+		assert.Len(t, actualBody, len(bodyStyles))
+		assert.Equal(t, 2, counts["sedan"])
+		assert.Equal(t, 1, counts["pickup"])
+		assert.Equal(t, 0, counts["suv"])
+	}
+}
+
 func TestCreateCar(t *testing.T) {
 	// Define the test cases as a table
 	testCases := []struct {
@@ -124,7 +585,7 @@ func TestCreateCar(t *testing.T) {
 		{
 			name:           "Valid Car",
 			expectedStatus: http.StatusCreated,
-			expectedBody:   `{"id": 1, "company": "Toyota", "model": "Corolla", "horsepower": "", "torque": "", "transmissionType": "", "drivetrain": "", "fuelEconomy": "", "numberOfDoors": "", "price": "", "startYear": 0, "endYear": 0, "bodyType": "", "engineType": "", "numberOfCylinders": "", "createdAt": "0001-01-01T00:00:00Z"}`,
+			expectedBody:   `{"id": 1, "company": "Toyota", "manufacturerId": 1, "model": "Corolla", "horsepower": "", "horsepowerMin": null, "horsepowerMax": null, "horsepowerUnit": "", "horsepowerRpmMin": null, "horsepowerRpmMax": null, "torque": "", "torqueMinNm": null, "torqueMaxNm": null, "torqueMinLbFt": null, "torqueMaxLbFt": null, "torqueRpmMin": null, "torqueRpmMax": null, "transmissionType": "", "transmissions": null, "drivetrain": "", "drivetrains": null, "fuelEconomy": "", "fuelEconomyCity": null, "fuelEconomyHighway": null, "fuelEconomyCombined": null, "fuelEconomyUnit": "", "fuelEconomySourceUnit": "", "numberOfDoors": "", "doors": null, "price": "", "priceCurrency": "", "priceMin": null, "priceMax": null, "priceIsStarting": false, "startYear": 0, "endYear": 0, "inProduction": false, "bodyType": "", "bodyTypes": null, "engineType": "", "engineDisplacements": null, "engineLayouts": null, "engineAspirations": null, "engineFuels": null, "numberOfCylinders": "", "cylinders": null, "createdAt": "0001-01-01T00:00:00Z"}`,
 			requestBody: `{"company": "Toyota", "model": "Corolla", "horsepower": "", "torque": "", "transmissionType": "", "drivetrain": "", "fuelEconomy": "", "numberOfDoors": "", "price": "", "startYear": 0, "endYear": 0, "bodyType": "", "engineType": "", "numberOfCylinders": ""}`,
 		},
 		{
@@ -133,6 +594,36 @@ func TestCreateCar(t *testing.T) {
 			expectedBody:   `{"message": "Received bad request."}`,
 			requestBody: `{"id":}`,
 		},
+		{
+			name:           "Invalid Drivetrain",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message": "Invalid car given: invalid drivetrain \"hover\", must be one of FWD, RWD, AWD, 4WD"}`,
+			requestBody: `{"company": "Toyota", "model": "Corolla", "drivetrains": ["hover"]}`,
+		},
+		{
+			name:           "Invalid Transmission",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message": "Invalid car given: invalid transmission kind \"hydraulic\", must be one of manual, automatic, DCT, CVT"}`,
+			requestBody: `{"company": "Toyota", "model": "Corolla", "transmissions": [{"kind": "hydraulic", "gears": 4}]}`,
+		},
+		{
+			name:           "Invalid Years",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message": "Invalid car given: a car in production can't have an end year"}`,
+			requestBody: `{"company": "Toyota", "model": "Corolla", "startYear": 2018, "endYear": 2020, "inProduction": true}`,
+		},
+		{
+			name:           "Invalid Body Type",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message": "Invalid car given: invalid body type \"tank\", must be one of sedan, coupe, convertible, roadster, hatchback, wagon, suv, crossover, pickup, van, minivan, sports-car, grand-tourer, city-car"}`,
+			requestBody: `{"company": "Toyota", "model": "Corolla", "bodyTypes": ["tank"]}`,
+		},
+		{
+			name:           "Duplicate Car",
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"message": "Car already exists."}`,
+			requestBody: `{"company": "DuplicateCompany", "model": "Corolla", "startYear": 2018}`,
+		},
 		{
 			name:           "Storage Issue",
 			expectedStatus: http.StatusInternalServerError,
@@ -172,4 +663,374 @@ func TestCreateCar(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestGetRejections(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	a := NewAPIServer(&MockDB{}, APIConfig{}, "")
+
+	a.getRejections(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var actualBody []*RejectedRow
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &actualBody)) && assert.Len(t, actualBody, 1) {
+		assert.Equal(t, "cars.csv", actualBody[0].SourceFile)
+		assert.Equal(t, 2, actualBody[0].LineNumber)
+		assert.JSONEq(t, `{"company": "Toyota", "model": "Corolla", "horsepower": "lots", "modelYearRange": "2015 - 2020"}`, string(actualBody[0].Record))
+	}
+}
+
+func TestRetryRejection(t *testing.T) {
+	testCases := []struct {
+		name            string
+		rejectionID     string
+		requestBody     string
+		expectedStatus  int
+		expectedMessage string
+	}{
+		{name: "Corrected", rejectionID: "1", requestBody: `{"horsepower": "132 hp"}`, expectedStatus: http.StatusCreated},
+		{name: "Still Invalid", rejectionID: "1", expectedStatus: http.StatusBadRequest, expectedMessage: `Row is still invalid: Horsepower: no numeric value found in "lots"`},
+		{name: "Invalid Corrections", rejectionID: "1", requestBody: `{"horsepower":}`, expectedStatus: http.StatusBadRequest, expectedMessage: "Invalid corrections given."},
+		{name: "Invalid Rejection ID", rejectionID: "456", expectedStatus: http.StatusNotFound, expectedMessage: "Rejected row not found."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.Default()
+			api := NewAPIServer(&MockDB{}, APIConfig{}, "")
+			router.POST("/admin/rejections/:id/retry", api.retryRejection)
+
+			req, _ := http.NewRequest("POST", "/admin/rejections/"+tc.rejectionID+"/retry", bytes.NewReader([]byte(tc.requestBody)))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+
+			var actualBody map[string]interface{}
+			if !assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &actualBody)) {
+				return
+			}
+			if tc.expectedStatus != http.StatusCreated {
+				assert.Equal(t, tc.expectedMessage, actualBody["message"])
+				return
+			}
+			assert.Equal(t, "Toyota", actualBody["company"])
+			assert.Equal(t, "132 hp", actualBody["horsepower"])
+			assert.Equal(t, float64(132), actualBody["horsepowerMin"])
+			assert.Equal(t, float64(2015), actualBody["startYear"])
+			assert.Equal(t, float64(1), actualBody["manufacturerId"])
+		})
+	}
+}
+
+func TestRequireAdminToken(t *testing.T) {
+	testCases := []struct {
+		name           string
+		adminToken     string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "Valid Token", adminToken: "secret", authorization: "Bearer secret", expectedStatus: http.StatusOK},
+		{name: "Invalid Token", adminToken: "secret", authorization: "Bearer guess", expectedStatus: http.StatusUnauthorized},
+		{name: "Missing Token", adminToken: "secret", expectedStatus: http.StatusUnauthorized},
+		{name: "Not A Bearer Token", adminToken: "secret", authorization: "secret", expectedStatus: http.StatusUnauthorized},
+		{name: "No Token Configured", authorization: "Bearer ", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.Default()
+			api := NewAPIServer(&MockDB{}, APIConfig{AdminToken: tc.adminToken}, "")
+			router.GET("/admin/rejections", api.requireAdminToken, api.getRejections)
+
+			req, _ := http.NewRequest("GET", "/admin/rejections", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestCreateImport(t *testing.T) {
+	router := gin.Default()
+	api := NewAPIServer(&MockDB{}, APIConfig{}, "")
+	router.POST("/admin/imports", api.createImport)
+	router.GET("/admin/imports/:id", api.getImport)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "upload.csv")
+	part.Write([]byte(`Company,Model,Horsepower,Torque,Transmission Type,Drivetrain,Fuel Economy,Number of Doors,Price,Model Year Range,Body Type,Engine Type,Number of Cylinders
+Ferrari,Roma,612 hp,561 lb-ft,8-speed automatic,RWD,17/24 mpg,2,"$222,620",2020 - Present,Coupe,3.9L V8,8
+Toyota,Corolla,lots,126 lb-ft,CVT,FWD,31/40 mpg,4,"$20,000",2015 - 2020,Sedan,Inline-4,4
+`))
+	form.Close()
+
+	req, _ := http.NewRequest("POST", "/admin/imports", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if !assert.Equal(t, http.StatusAccepted, rec.Code) {
+		return
+	}
+	var job ImportJob
+	if !assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job)) {
+		return
+	}
+	assert.Equal(t, "upload.csv", job.Filename)
+
+	// the import runs in the background so the job is polled until it's done
+	path := "/admin/imports/" + strconv.Itoa(job.ID)
+	assert.Eventually(t, func() bool {
+		req, _ := http.NewRequest("GET", path, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		job = ImportJob{}
+		return json.Unmarshal(rec.Body.Bytes(), &job) == nil && job.Status == importSucceeded
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, 2, job.Total)
+	assert.Equal(t, 2, job.Processed)
+	if assert.NotNil(t, job.Summary) {
+		assert.Equal(t, 1, job.Summary.Rejected)
+	}
+	assert.Equal(t, []RowError{{Line: 3, Error: `Horsepower: no numeric value found in "lots"`}}, job.Errors)
+}
+
+func TestCreateImportWithoutFile(t *testing.T) {
+	router := gin.Default()
+	api := NewAPIServer(&MockDB{}, APIConfig{}, "")
+	router.POST("/admin/imports", api.createImport)
+
+	req, _ := http.NewRequest("POST", "/admin/imports", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"message": "No file given."}`, rec.Body.String())
+}
+
+func TestGetImport(t *testing.T) {
+	testCases := []struct {
+		name           string
+		jobID          string
+		expectedStatus int
+	}{
+		{name: "Valid Import ID", jobID: "1", expectedStatus: http.StatusOK},
+		{name: "Unknown Import ID", jobID: "2", expectedStatus: http.StatusNotFound},
+		{name: "Invalid Import ID", jobID: "abc", expectedStatus: http.StatusNotFound},
+	}
+
+	router := gin.Default()
+	api := NewAPIServer(&MockDB{}, APIConfig{}, "")
+	api.imports.add("cars.csv")
+	router.GET("/admin/imports/:id", api.getImport)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/admin/imports/"+tc.jobID, nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestReplaceCar(t *testing.T) {
+	testCases := []struct {
+		name            string
+		carID           string
+		requestBody     string
+		expectedStatus  int
+		expectedMessage string
+	}{
+		{name: "Valid Car", carID: "1", requestBody: `{"company": "Toyota", "model": "Camry", "startYear": 2018}`, expectedStatus: http.StatusOK},
+		{name: "Invalid Car", carID: "1", requestBody: `{"company": "Toyota", "model": "Camry", "drivetrains": ["hover"]}`, expectedStatus: http.StatusBadRequest, expectedMessage: `Invalid car given: invalid drivetrain "hover", must be one of FWD, RWD, AWD, 4WD`},
+		{name: "Duplicate Car", carID: "1", requestBody: `{"company": "DuplicateCompany", "model": "Corolla"}`, expectedStatus: http.StatusConflict, expectedMessage: "Car already exists."},
+		{name: "Missing Car", carID: "456", requestBody: `{"company": "Toyota", "model": "Camry"}`, expectedStatus: http.StatusNotFound, expectedMessage: "Car not found."},
+		{name: "Invalid Car ID", carID: "first", requestBody: `{"company": "Toyota", "model": "Camry"}`, expectedStatus: http.StatusNotFound, expectedMessage: "Car not found."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.Default()
+			api := NewAPIServer(&MockDB{}, APIConfig{}, "")
+			router.PUT("/cars/:id", api.replaceCar)
+
+			req, _ := http.NewRequest("PUT", "/cars/"+tc.carID, strings.NewReader(tc.requestBody))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+
+			var actualBody map[string]any
+			if !assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &actualBody)) {
+				return
+			}
+			if tc.expectedStatus != http.StatusOK {
+				assert.Equal(t, tc.expectedMessage, actualBody["message"])
+				return
+			}
+			assert.Equal(t, float64(1), actualBody["id"])
+			assert.Equal(t, "Camry", actualBody["model"])
+		})
+	}
+}
+
+func TestPatchCar(t *testing.T) {
+	testCases := []struct {
+		name            string
+		carID           string
+		contentType     string
+		requestBody     string
+		expectedStatus  int
+		expectedFields  map[string]any
+		expectedMessage string
+	}{
+		{
+			name:           "Changed Field",
+			carID:          "1",
+			contentType:    mergePatchContentType,
+			requestBody:    `{"model": "Camry", "drivetrain": "AWD"}`,
+			expectedStatus: http.StatusOK,
+			expectedFields: map[string]any{"company": "Toyota", "model": "Camry", "drivetrain": "AWD", "drivetrains": []any{"AWD"}},
+		},
+		{
+			name:           "Plain JSON",
+			carID:          "1",
+			contentType:    "application/json",
+			requestBody:    `{"startYear": 2018, "inProduction": true}`,
+			expectedStatus: http.StatusOK,
+			expectedFields: map[string]any{"model": "Corolla", "startYear": float64(2018), "inProduction": true},
+		},
+		{
+			name:            "Invalid Result",
+			carID:           "1",
+			contentType:     mergePatchContentType,
+			requestBody:     `{"startYear": 2018, "endYear": 2020, "inProduction": true}`,
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "Invalid car given: a car in production can't have an end year",
+		},
+		{
+			name:            "Wrong Type",
+			carID:           "1",
+			contentType:     mergePatchContentType,
+			requestBody:     `{"startYear": "2018"}`,
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "Invalid car given.",
+		},
+		{
+			name:            "Not An Object",
+			carID:           "1",
+			contentType:     mergePatchContentType,
+			requestBody:     `["model"]`,
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "Invalid patch given, it must be a JSON object.",
+		},
+		{
+			name:            "Missing Car",
+			carID:           "456",
+			contentType:     mergePatchContentType,
+			requestBody:     `{"model": "Camry"}`,
+			expectedStatus:  http.StatusNotFound,
+			expectedMessage: "Car not found.",
+		},
+		{
+			name:            "Unsupported Content Type",
+			carID:           "1",
+			contentType:     "text/plain",
+			requestBody:     `{"model": "Camry"}`,
+			expectedStatus:  http.StatusUnsupportedMediaType,
+			expectedMessage: "Unsupported content type, patches must be application/merge-patch+json.",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.Default()
+			api := NewAPIServer(&MockDB{}, APIConfig{}, "")
+			router.PATCH("/cars/:id", api.patchCar)
+
+			req, _ := http.NewRequest("PATCH", "/cars/"+tc.carID, strings.NewReader(tc.requestBody))
+			req.Header.Set("Content-Type", tc.contentType)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+
+			var actualBody map[string]any
+			if !assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &actualBody)) {
+				return
+			}
+			if tc.expectedStatus != http.StatusOK {
+				assert.Equal(t, tc.expectedMessage, actualBody["message"])
+				return
+			}
+			for field, expected := range tc.expectedFields {
+				assert.Equal(t, expected, actualBody[field], field)
+			}
+		})
+	}
+}
+
+func TestDeleteCar(t *testing.T) {
+	testCases := []struct {
+		name           string
+		carID          string
+		expectedStatus int
+	}{
+		{name: "Valid Car ID", carID: "1", expectedStatus: http.StatusNoContent},
+		{name: "Invalid Car ID", carID: "456", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.Default()
+			api := NewAPIServer(&MockDB{}, APIConfig{}, "")
+			router.DELETE("/cars/:id", api.deleteCar)
+
+			req, _ := http.NewRequest("DELETE", "/cars/"+tc.carID, nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// examples from RFC 7386
+	testCases := []struct {
+		name     string
+		target   string
+		patch    string
+		expected string
+	}{
+		{name: "Replaced Member", target: `{"a": "b"}`, patch: `{"a": "c"}`, expected: `{"a": "c"}`},
+		{name: "Added Member", target: `{"a": "b"}`, patch: `{"b": "c"}`, expected: `{"a": "b", "b": "c"}`},
+		{name: "Removed Member", target: `{"a": "b", "b": "c"}`, patch: `{"a": null}`, expected: `{"b": "c"}`},
+		{name: "Nested Object", target: `{"a": {"b": "c"}}`, patch: `{"a": {"b": "d", "c": null}}`, expected: `{"a": {"b": "d"}}`},
+		{name: "Replaced Array", target: `{"a": ["b"]}`, patch: `{"a": ["c"]}`, expected: `{"a": ["c"]}`},
+		{name: "Object Onto Value", target: `{"a": "b"}`, patch: `{"a": {"b": null, "c": "d"}}`, expected: `{"a": {"c": "d"}}`},
+		{name: "Value Onto Object", target: `{"a": {"b": "c"}}`, patch: `["c"]`, expected: `["c"]`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var target, patch, expected any
+			if !assert.NoError(t, json.Unmarshal([]byte(tc.target), &target)) ||
+				!assert.NoError(t, json.Unmarshal([]byte(tc.patch), &patch)) ||
+				!assert.NoError(t, json.Unmarshal([]byte(tc.expected), &expected)) {
+				return
+			}
+			assert.Equal(t, expected, mergePatch(target, patch))
+		})
+	}
+}
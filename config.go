@@ -1,9 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	log "golang.org/x/exp/slog"
 
@@ -12,23 +14,26 @@ import (
 
 // Config holds the configuration values
 type Config struct {
-	Environment string
-	API         APIConfig
-	Log         LogLevel
-	Database    DatabaseConfig
-	CSV         CSVConfig
+	Environment   string
+	API           APIConfig
+	Log           LogLevel
+	Database      DatabaseConfig
+	CSV           CSVConfig
+	Manufacturers ManufacturersConfig
 }
 
 // APIConfig holds the API configuration values
 type APIConfig struct {
 	Address string
 	Path    string
+	// bearer token of the admin endpoints, the ADMIN_TOKEN env var takes precedence
+	AdminToken string `mapstructure:"admin_token"`
 }
 
 // LogLevel holds the log configuration values
 type LogLevel struct {
-	LevelStr string
-	Level    log.Level
+	LevelStr string    `mapstructure:"level"`
+	Level    log.Level `mapstructure:"-"`
 }
 
 // DatabaseConfig holds the database configuration values
@@ -47,6 +52,24 @@ type SSL struct {
 // CSVConfig holds the CSV configuration values.
 type CSVConfig struct {
 	Filename string
+	// how long an import may take before it's rolled back (i.e. "60s" or "5m")
+	Timeout time.Duration
+	// character the values are separated by, a comma when not given
+	Delimiter string
+	// encoding of the file (i.e. "windows-1252"), UTF-8 when not given
+	Encoding string
+	// line of the header, the lines above it are skipped. The first line when not given
+	HeaderRow int `mapstructure:"header_row"`
+	// header each field of a car is read from, by the field's JSON name
+	Columns map[string]string
+	// reimport the file whenever it changes, checking it every interval ("10s" when not given)
+	Watch         bool
+	WatchInterval time.Duration `mapstructure:"watch_interval"`
+}
+
+// ManufacturersConfig holds the manufacturers configuration values.
+type ManufacturersConfig struct {
+	Filename string
 }
 
 // LoadConfig loads the configuration values from the specified file.
@@ -71,12 +94,29 @@ func LoadConfig(file string) (*Config, error) {
 		return nil, err
 	}
 
+	// The values for each environment are nested under its name
+	env := viper.GetString("env")
+	envConfig := viper.Sub(env)
+	if envConfig == nil {
+		return nil, fmt.Errorf("no configuration found for env %q", env)
+	}
+
 	// Unmarshal the configuration values into the Config struct
 	var config Config
-	err = viper.Unmarshal(&config)
+	err = envConfig.Unmarshal(&config)
 	if err != nil {
 		return nil, err
 	}
+	config.Environment = env
+	// secrets are better kept out of the config file
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		config.API.AdminToken = token
+	}
+
+	// a bad mapping would otherwise only show once a file is imported
+	if _, err := newCSVRecordReader(config.CSV); err != nil {
+		return nil, fmt.Errorf("invalid csv config: %w", err)
+	}
 	log.Info(config.Log.LevelStr)
 	// Get a valid slog log level
 	config.Log.Level = GetLogLevel(config.Log.LevelStr)
@@ -84,6 +124,26 @@ func LoadConfig(file string) (*Config, error) {
 	return &config, nil
 }
 
+// LoadManufacturers loads the manufacturers listed in the given YAML file
+func LoadManufacturers(file string) ([]*Manufacturer, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	var manufacturers []*Manufacturer
+	if err := v.UnmarshalKey("manufacturers", &manufacturers); err != nil {
+		return nil, err
+	}
+	for _, manufacturer := range manufacturers {
+		if manufacturer.Name == "" {
+			return nil, fmt.Errorf("manufacturer without a name found in %s", file)
+		}
+	}
+	return manufacturers, nil
+}
+
 // GetConfigFilePath returns the absolute path of the config file based on the current directory.
 func GetConfigFilePath() string {
 	dir, err := os.Getwd()
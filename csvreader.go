@@ -2,92 +2,1095 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"os"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/gocarina/gocsv"
 	log "golang.org/x/exp/slog"
 )
 
-func CsvReader(file *os.File, db CarDB) error {
-	carRecords := []*CarRecord{}
-	if err := gocsv.Unmarshal(file, &carRecords); err != nil {
-		log.Error("Unable to unmarshal file contents", "filename", file.Name(), "err", err)
-		return err
+// defaultImportTimeout is how long an import may take when no timeout is configured
+const defaultImportTimeout = 60 * time.Second
+
+// importing makes imports run one at a time so that two of them can't upsert the same cars
+// at once
+var importing sync.Mutex
+
+// CsvReader imports a CSV file, see ImportFile
+func CsvReader(file *os.File, db CarDB, timeout time.Duration) (*ImportSummary, error) {
+	return ImportFile(file, csvRecordReader{}, db, timeout)
+}
+
+// ImportFile cleans every record of the file then stores them all at once. Records that
+// can't be cleaned, or that repeat a car found earlier in the file, are rejected (and stored
+// as such) so the rest of the file still gets imported. Importing the same file again only
+// updates the cars that changed. A timeout of zero falls back to defaultImportTimeout
+func ImportFile(file *os.File, reader RecordReader, db CarDB, timeout time.Duration) (*ImportSummary, error) {
+	importing.Lock()
+	defer importing.Unlock()
+	return importRecords(file, reader, file.Name(), db, timeout, nil)
+}
+
+// rowProgress is told about every row of an import once it's been cleaned, along with the
+// error it was rejected for if any
+type rowProgress func(line, total int, err error)
+
+// importRecords does the work of ImportFile for any io.Reader, with the source being what
+// the records are imported (and rejected) as coming from. The caller holds importing
+func importRecords(r io.Reader, reader RecordReader, source string, db CarDB, timeout time.Duration, progress rowProgress) (*ImportSummary, error) {
+	carRecords, err := readRecords(reader, r, source)
+	if err != nil {
+		return nil, err
+	}
+	if progress == nil {
+		progress = func(int, int, error) {}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	if timeout <= 0 {
+		timeout = defaultImportTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
+
+	manufacturers, err := db.GetManufacturers(ctx)
+	if err != nil {
+		log.Error("Unable to retrieve manufacturers", "err", err)
+		return nil, err
+	}
+	index := newManufacturerIndex(manufacturers)
 	
 	// set all created times to the same time
 	createdAt := time.Now().UTC()
 
-	// loop through each record that was unmarshalled from the CSV then clean
-	// up the year range such that it is stored in as start- and end-year
+	cars := make([]*Car, 0, len(carRecords))
+	var rejected []*RejectedRow
+	// line each car was first seen on
+	seen := make(map[naturalKey]int, len(carRecords))
 	for _, carRecord := range carRecords {
-		err := clean(carRecord); if err != nil {
-			log.Error("There was an issue cleaning a CarRecord", "car", carRecord)
-			return err
+		line := carRecord.Line
+		// the record is kept as it was read in case it gets rejected
+		record, err := json.Marshal(carRecord)
+		if err != nil {
+			return nil, err
+		}
+
+		err = carRecord.Err
+		if err == nil {
+			err = clean(carRecord)
 		}
-		
+		if err != nil {
+			log.Error("There was an issue cleaning a CarRecord", "car", carRecord.String(), "err", err)
+			rejected = append(rejected, &RejectedRow{SourceFile: source, LineNumber: line, Record: record, Error: err.Error(), CreatedAt: createdAt})
+			progress(line, len(carRecords), err)
+			continue
+		}
+
 		car := carRecord.Car
+		if err := checkColumns(car); err != nil {
+			log.Error("A CarRecord doesn't fit the cars table", "car", carRecord.String(), "err", err)
+			rejected = append(rejected, &RejectedRow{SourceFile: source, LineNumber: line, Record: record, Error: err.Error(), CreatedAt: createdAt})
+			progress(line, len(carRecords), err)
+			continue
+		}
+		if first, ok := seen[car.naturalKey()]; ok {
+			err := fmt.Errorf("duplicate of line %d", first)
+			log.Warn("Skipping a duplicate car", "car", carRecord.String(), "line", line, "first", first)
+			rejected = append(rejected, &RejectedRow{SourceFile: source, LineNumber: line, Record: record, Error: err.Error(), CreatedAt: createdAt})
+			progress(line, len(carRecords), err)
+			continue
+		}
+		seen[car.naturalKey()] = line
+
+		index.resolve(car)
 		car.CreatedAt = createdAt
-		_, err = db.CreateCar(ctx, car); if err != nil {
-			log.Error("Could not insert Car into database", "car", car.String(), "err", err)
-			return err
+		cars = append(cars, car)
+		progress(line, len(carRecords), nil)
+	}
+
+	summary, err := db.ImportCars(ctx, source, cars, rejected)
+	if err != nil {
+		log.Error("Could not import Cars into database", "filename", source, "err", err)
+		return nil, err
+	}
+
+	log.Info("Finished importing cars", "filename", source, "version", summary.Version, "inserted", summary.Inserted, "updated", summary.Updated, "unchanged", summary.Unchanged, "rejected", summary.Rejected)
+	for _, update := range summary.Updates {
+		log.Info("Updated car", "id", update.ID, "car", update.Car, "fields", update.Fields)
+	}
+	return summary, nil
+}
+
+// DryRun reads and cleans every record of the file the same way ImportFile does, without
+// touching the database, and reports the errors and warnings found in each row. Companies
+// are resolved against the given manufacturers
+func DryRun(file *os.File, reader RecordReader, manufacturers []*Manufacturer) (*ImportReport, error) {
+	carRecords, err := readRecords(reader, file, file.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	index := newManufacturerIndex(manufacturers)
+	report := &ImportReport{Filename: file.Name(), Rows: len(carRecords), Issues: []RowReport{}}
+	for _, carRecord := range carRecords {
+		row := RowReport{Line: carRecord.Line, Car: carRecord.String()}
+
+		err := carRecord.Err
+		if err == nil {
+			err = clean(carRecord)
+		}
+		if err == nil {
+			err = checkColumns(carRecord.Car)
+		}
+		if err != nil {
+			var issues Issues
+			if !errors.As(err, &issues) {
+				issues = Issues{{Message: err.Error()}}
+			}
+			row.Errors = issues
+			report.Invalid++
+		} else {
+			report.Valid++
+		}
+		index.resolve(carRecord.Car)
+		row.Warnings = carRecord.Warnings
+		report.Warnings += len(row.Warnings)
+
+		if len(row.Errors) > 0 || len(row.Warnings) > 0 {
+			report.Issues = append(report.Issues, row)
 		}
 	}
+	return report, nil
+}
 
-	return nil
+// readRecords reads every record of the source with the reader of its format
+func readRecords(reader RecordReader, r io.Reader, source string) ([]*CarRecord, error) {
+	carRecords, err := reader.Read(r)
+	if err != nil {
+		log.Error("Unable to unmarshal file contents", "filename", source, "err", err)
+		return nil, err
+	}
+	return carRecords, nil
 }
 
-// clean takes a car and cleans up the data for the model year range
+// clean takes a car and cleans up the data for the model year range and
+// parses the free-text specs into their structured fields
 func clean(c *CarRecord) error {
-	err := cleanYears(c)
-	// err = cleanPrice(c)
-	return err
+	c.Warnings = nil
+	cleaners := []struct {
+		column string
+		clean  func(*CarRecord) error
+	}{
+		{columnModelYearRange, cleanYears},
+		{columnHorsepower, cleanHorsepower},
+		{columnTorque, cleanTorque},
+		{columnPrice, cleanPrice},
+		{columnFuelEconomy, cleanFuelEconomy},
+		{columnDrivetrain, cleanDrivetrain},
+		{columnTransmissionType, cleanTransmission},
+		{columnEngineType, cleanEngine},
+		{columnNumberOfCylinders, cleanCylinders},
+		{columnNumberOfDoors, cleanDoors},
+		{columnBodyType, cleanBodyType},
+	}
+
+	// every column is cleaned, even after an error, so that all of a row's issues are found at once
+	var errs Issues
+	for _, cleaner := range cleaners {
+		if err := cleaner.clean(c); err != nil {
+			errs = append(errs, Issue{Column: cleaner.column, Message: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
+// column names of the dataset that issues are reported against
+const (
+	columnCompany           = "Company"
+	columnModel             = "Model"
+	columnModelYearRange    = "Model Year Range"
+	columnHorsepower        = "Horsepower"
+	columnTorque            = "Torque"
+	columnTransmissionType  = "Transmission Type"
+	columnDrivetrain        = "Drivetrain"
+	columnFuelEconomy       = "Fuel Economy"
+	columnNumberOfDoors     = "Number of Doors"
+	columnPrice             = "Price"
+	columnBodyType          = "Body Type"
+	columnEngineType        = "Engine Type"
+	columnNumberOfCylinders = "Number of Cylinders"
+)
+
 func cleanYears(c *CarRecord) error {
+	// cars given through the API come with their years already split up
+	if strings.TrimSpace(c.ModelYearRange) == "" {
+		return nil
+	}
+
+	years, err := parseYearRange(c.ModelYearRange)
+	if err != nil {
+		log.Error("There was an issue cleaning the Model Year Range", "modelYearRange", c.ModelYearRange, "err", err)
+		return err
+	}
+
+	car := c.Car
+	car.StartYear, car.EndYear, car.InProduction = years.StartYear, years.EndYear, years.InProduction
+	return nil
+}
+
+var (
+	// en and em dashes are used as often as hyphens, as is "to"
+	yearRangeDashReplacer = strings.NewReplacer("–", "-", "—", "-", " to ", "-")
+	// matches "2008", "2008-2012", "2008 - 12", "2019-present", "2019 onwards" and "2019+"
+	yearRangeRegex = regexp.MustCompile(`^(\d{4})(?:\s*-\s*(\d{4}|\d{2})|\s*-?\s*(present|current|now|onwards?|\+))?$`)
+)
+
+// parseYearRange parses a model year range. A single year is a range of its own and
+// open-ended ranges are kept open (rather than ending this year) so they don't go stale
+func parseYearRange(s string) (*YearRange, error) {
+	s = strings.ToLower(strings.TrimSpace(yearRangeDashReplacer.Replace(s)))
+	match := yearRangeRegex.FindStringSubmatch(s)
+	if match == nil {
+		return nil, fmt.Errorf("unrecognized model year range %q", s)
+	}
+
+	start, _ := strconv.Atoi(match[1])
+	years := &YearRange{StartYear: start}
+	switch {
+	case match[2] != "":
+		end, _ := strconv.Atoi(match[2])
+		// a two-digit end year is in the start year's century (i.e. "2008-12")
+		if len(match[2]) == 2 {
+			end += start / 100 * 100
+		}
+		if end < start {
+			return nil, fmt.Errorf("end year %d is before start year %d", end, start)
+		}
+		years.EndYear = &end
+	case match[3] != "":
+		years.InProduction = true
+	default:
+		years.EndYear = &start
+	}
+	return years, nil
+}
+
+// cleanHorsepower parses the horsepower (i.e. "789 hp", "117 bhp @ 3500 rpm" or "200-308")
+// into its min/max values, unit and the rpm it's rated at. The raw string is left untouched
+func cleanHorsepower(c *CarRecord) error {
+	car := c.Car
+	car.HorsepowerMin, car.HorsepowerMax, car.HorsepowerUnit = nil, nil, ""
+	car.HorsepowerRPMMin, car.HorsepowerRPMMax = nil, nil
+
+	power, err := parseRatedValue(car.Horsepower, horsepowerRegex, powerUnits, powerUnitHP)
+	if err != nil {
+		log.Error("There was an issue cleaning the Horsepower", "horsepower", car.Horsepower, "err", err)
+		return err
+	}
+	if power == nil {
+		return nil
+	}
+
+	min, max := int(math.Round(power.Min)), int(math.Round(power.Max))
+	car.HorsepowerMin, car.HorsepowerMax = &min, &max
+	car.HorsepowerUnit = power.Unit
+	car.HorsepowerRPMMin, car.HorsepowerRPMMax = power.RPMMin, power.RPMMax
+	return nil
+}
+
+// cleanTorque parses the torque (i.e. "530 lb-ft", "250 Nm @ 1500-2000 rpm" or "191-369")
+// into its min/max values in both Nm and lb-ft along with the rpm range it's produced at.
+// Bare numbers are assumed to be lb-ft since that's what the rest of the dataset mostly uses
+func cleanTorque(c *CarRecord) error {
+	car := c.Car
+	car.TorqueMinNm, car.TorqueMaxNm, car.TorqueMinLbFt, car.TorqueMaxLbFt = nil, nil, nil, nil
+	car.TorqueRPMMin, car.TorqueRPMMax = nil, nil
+
+	torque, err := parseRatedValue(car.Torque, torqueRegex, torqueUnits, torqueUnitLbFt)
+	if err != nil {
+		log.Error("There was an issue cleaning the Torque", "torque", car.Torque, "err", err)
+		return err
+	}
+	if torque == nil {
+		return nil
+	}
+
+	minNm, maxNm := torque.Min, torque.Max
+	minLbFt, maxLbFt := torque.Min, torque.Max
+	if torque.Unit == torqueUnitLbFt {
+		minNm, maxNm = minNm*newtonMetresPerPoundFoot, maxNm*newtonMetresPerPoundFoot
+	} else {
+		minLbFt, maxLbFt = minLbFt/newtonMetresPerPoundFoot, maxLbFt/newtonMetresPerPoundFoot
+	}
+
+	minNm, maxNm = roundTo(minNm, 1), roundTo(maxNm, 1)
+	minLbFt, maxLbFt = roundTo(minLbFt, 1), roundTo(maxLbFt, 1)
+	car.TorqueMinNm, car.TorqueMaxNm = &minNm, &maxNm
+	car.TorqueMinLbFt, car.TorqueMaxLbFt = &minLbFt, &maxLbFt
+	car.TorqueRPMMin, car.TorqueRPMMax = torque.RPMMin, torque.RPMMax
+	return nil
+}
+
+const (
+	torqueUnitNm   = "Nm"
+	torqueUnitLbFt = "lb-ft"
+
+	newtonMetresPerPoundFoot = 1.3558179483
+)
+
+// torqueUnits maps the (lowercased) units found in the dataset to the ones we store
+var torqueUnits = map[string]string{
+	"nm":     torqueUnitNm,
+	"lb-ft":  torqueUnitLbFt,
+	"lb. ft": torqueUnitLbFt,
+	"lb.ft":  torqueUnitLbFt,
+}
+
+// a number (possibly with thousands separators) optionally followed by its unit
+var torqueRegex = regexp.MustCompile(`(?i)(\d[\d,]*(?:\.\d+)?)\s*(lb-ft|lb\.\s?ft|nm)?`)
+
+const (
+	powerUnitHP  = "hp"
+	powerUnitBHP = "bhp"
+	powerUnitPS  = "PS"
+	powerUnitKW  = "kW"
+)
+
+// powerUnits maps the (lowercased) units found in the dataset to the ones we store
+var powerUnits = map[string]string{
+	"hp":  powerUnitHP,
+	"bhp": powerUnitBHP,
+	"ps":  powerUnitPS,
+	"kw":  powerUnitKW,
+}
+
+var (
+	// matches "@ 3500 rpm", "at 4000 rpm" and "@ 1500-2000 rpm"
+	rpmRegex = regexp.MustCompile(`(?i)(?:@|\bat\b)\s*(\d[\d,]*)(?:\s*-\s*(\d[\d,]*))?\s*rpm`)
+	// parenthesized text only ever holds conversions or notes, i.e. "160 PS (158 bhp - 118 kW)"
+	parenthesesRegex = regexp.MustCompile(`\([^)]*\)`)
+	// a number (possibly with thousands separators) optionally followed by its unit
+	horsepowerRegex = regexp.MustCompile(`(?i)(\d[\d,]*(?:\.\d+)?)\s*(bhp|hp|ps|kw)?`)
+)
+
+// ratedValue is a numeric spec parsed from free text, optionally rated at an rpm (range)
+type ratedValue struct {
+	Min    float64
+	Max    float64
+	Unit   string
+	RPMMin *int
+	RPMMax *int
+}
+
+// parseRatedValue pulls every number out of s using re, whose first group must be the number
+// and second group the (optional) unit. Numbers in a unit other than the first one seen are
+// ignored. A nil value is returned for blank or "N/A" specs
+func parseRatedValue(s string, re *regexp.Regexp, units map[string]string, defaultUnit string) (*ratedValue, error) {
+	spec := strings.TrimSpace(s)
+	if spec == "" || strings.EqualFold(spec, "N/A") {
+		return nil, nil
+	}
+
+	value := &ratedValue{}
+	if match := rpmRegex.FindStringSubmatch(spec); match != nil {
+		rpmMin, err := parseNumber(match[1])
+		if err != nil {
+			return nil, err
+		}
+		min := int(rpmMin)
+		value.RPMMin, value.RPMMax = &min, &min
+		if match[2] != "" {
+			rpmMax, err := parseNumber(match[2])
+			if err != nil {
+				return nil, err
+			}
+			max := int(rpmMax)
+			value.RPMMax = &max
+		}
+		spec = rpmRegex.ReplaceAllString(spec, "")
+	}
+	spec = parenthesesRegex.ReplaceAllString(spec, "")
+
+	found := false
+	for _, match := range re.FindAllStringSubmatch(spec, -1) {
+		unit := units[strings.ToLower(match[2])]
+		if unit != "" && value.Unit == "" {
+			value.Unit = unit
+		}
+		if unit != "" && unit != value.Unit {
+			continue
+		}
+
+		n, err := parseNumber(match[1])
+		if err != nil {
+			return nil, err
+		}
+		if !found || n < value.Min {
+			value.Min = n
+		}
+		if !found || n > value.Max {
+			value.Max = n
+		}
+		found = true
+	}
+
+	if !found {
+		return nil, fmt.Errorf("no numeric value found in %q", s)
+	}
+	if value.Unit == "" {
+		value.Unit = defaultUnit
+	}
+	return value, nil
+}
+
+// roundTo rounds f to the given number of decimal places
+func roundTo(f float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(f*scale) / scale
+}
+
+// parseNumber parses numbers that may contain thousands separators (i.e. "1,001")
+func parseNumber(s string) (float64, error) {
+	return strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+}
+
+// cleanFuelEconomy parses the fuel economy (i.e. "13/20 mpg", "34 mpg (city)/41 mpg (highway)",
+// "20 city / 27 highway" or "13.1 kmpl") into city/highway/combined values stored in L/100km.
+// The unit it was published in is kept as the source unit. Electric range figures ("310 miles")
+// aren't an economy so they're left null along with unknowns. Bare ranges ("17-22 mpg") are the
+// spread across trims rather than city and highway, so they're left null with a warning
+func cleanFuelEconomy(c *CarRecord) error {
+	car := c.Car
+	car.FuelEconomyCity, car.FuelEconomyHighway, car.FuelEconomyCombined = nil, nil, nil
+	car.FuelEconomyUnit, car.FuelEconomySourceUnit = "", ""
+
+	economy := strings.ToLower(strings.TrimSpace(car.FuelEconomy))
+	if economy == "" || economy == "n/a" || strings.Contains(economy, "miles") {
+		return nil
+	}
+
+	// anything after a comma or a spaced hyphen is either a restatement in other
+	// units or trivia (i.e. "20 mpg (Average), 0 to 60 mph in 5.3 seconds"), unless
+	// the hyphen is between two numbers ("17 - 22 mpg")
+	economy, _, _ = strings.Cut(economy, ",")
+	economy = fuelEconomySpacedRangeRegex.ReplaceAllString(economy, "$1-$2")
+	economy, _, _ = strings.Cut(economy, " - ")
+
+	unit := fuelEconomyUnitMPG
+	if match := fuelEconomyUnitRegex.FindString(economy); match != "" {
+		unit = fuelEconomyUnits[strings.ReplaceAll(match, " ", "")]
+	}
+	economy = fuelEconomyUnitRegex.ReplaceAllString(economy, " ")
+
+	var city, highway, combined []string
+	if strings.Contains(economy, "city") && strings.Contains(economy, "highway") {
+		// labelled values (i.e. "16-20 mpg city / 22-26 mpg highway") use the first number of each
+		for _, part := range strings.Split(economy, "/") {
+			numbers := fuelEconomyRegex.FindAllString(part, 1)
+			switch {
+			case strings.Contains(part, "city"):
+				city = numbers
+			case strings.Contains(part, "highway"):
+				highway = numbers
+			}
+		}
+	} else {
+		numbers := fuelEconomyRegex.FindAllString(economy, -1)
+		if len(numbers) == 2 && fuelEconomyRangeRegex.MatchString(economy) {
+			car.warn(columnFuelEconomy, "range %q doesn't tell city from highway, leaving it empty", car.FuelEconomy)
+			return nil
+		}
+		switch len(numbers) {
+		case 1:
+			combined = numbers
+		case 2:
+			city, highway = numbers[:1], numbers[1:]
+		}
+	}
+
+	if len(city)+len(highway)+len(combined) == 0 {
+		err := fmt.Errorf("could not find city, highway or combined values in fuel economy %q", car.FuelEconomy)
+		log.Error("There was an issue cleaning the Fuel Economy", "fuelEconomy", car.FuelEconomy, "err", err)
+		return err
+	}
+
 	var err error
-	
-	trimmedYearRange := strings.ReplaceAll(c.ModelYearRange, " ", "")
-	if trimmedYearRange == "" {
+	for _, value := range []struct {
+		numbers []string
+		dest    **float64
+	}{
+		{city, &car.FuelEconomyCity},
+		{highway, &car.FuelEconomyHighway},
+		{combined, &car.FuelEconomyCombined},
+	} {
+		if len(value.numbers) == 0 {
+			continue
+		}
+		var n float64
+		if n, err = parseNumber(value.numbers[0]); err != nil {
+			log.Error("There was an issue cleaning the Fuel Economy", "fuelEconomy", car.FuelEconomy, "err", err)
+			return err
+		}
+		litres := roundTo(convertFuelEconomy(n, unit), 2)
+		*value.dest = &litres
+	}
+
+	car.FuelEconomyUnit = fuelEconomyUnitL100km
+	car.FuelEconomySourceUnit = unit
+	return nil
+}
+
+// fuelEconomyUnits maps the (lowercased, unspaced) units found in the dataset to the ones we store
+var fuelEconomyUnits = map[string]string{
+	"mpg":     fuelEconomyUnitMPG,
+	"mpge":    fuelEconomyUnitMPGe,
+	"mpg-e":   fuelEconomyUnitMPGe,
+	"kmpl":    fuelEconomyUnitKmpl,
+	"km/l":    fuelEconomyUnitKmpl,
+	"l/100km": fuelEconomyUnitL100km,
+}
+
+var (
+	fuelEconomyUnitRegex  = regexp.MustCompile(`mpg-?e|mpg|kmpl|km/l|l/100\s?km`)
+	fuelEconomyRegex      = regexp.MustCompile(`\d+(?:\.\d+)?`)
+	fuelEconomyRangeRegex = regexp.MustCompile(`\d+(?:\.\d+)?\s*-\s*\d+(?:\.\d+)?`)
+	// a range written with spaces around its hyphen
+	fuelEconomySpacedRangeRegex = regexp.MustCompile(`(\d+(?:\.\d+)?)\s+-\s+(\d)`)
+)
+
+// cleanDrivetrain normalizes the drivetrain (i.e. "RWD", "Front-wheel drive or all-wheel drive"
+// or "RWD/4WD") into the set of canonical drivetrains. When the drivetrains are already given
+// (i.e. through the API) they're canonicalized instead and the raw value filled in if missing.
+// The dataset has values that aren't drivetrains at all (i.e. "0-60 mph in 3 seconds") so
+// unrecognized values are logged and left empty rather than failing the whole import
+func cleanDrivetrain(c *CarRecord) error {
+	car := c.Car
+	if len(car.Drivetrains) > 0 {
+		for i, drivetrain := range car.Drivetrains {
+			if canonical, ok := drivetrainAliases[strings.ToLower(strings.TrimSpace(drivetrain))]; ok {
+				car.Drivetrains[i] = canonical
+			}
+		}
+		if car.Drivetrain == "" {
+			car.Drivetrain = strings.Join(car.Drivetrains, "/")
+		}
+		return nil
+	}
+
+	var err error
+	car.Drivetrains, err = parseDrivetrains(car.Drivetrain)
+	if err != nil {
+		car.warn(columnDrivetrain, "%s, leaving it empty", err)
+	}
+	return nil
+}
+
+// drivetrainAliases maps the (lowercased) drivetrains found in the dataset to their canonical value
+var drivetrainAliases = map[string]string{
+	"fwd":               DrivetrainFWD,
+	"front-wheel drive": DrivetrainFWD,
+	"front wheel drive": DrivetrainFWD,
+	"rwd":               DrivetrainRWD,
+	"rear-wheel drive":  DrivetrainRWD,
+	"rear wheel drive":  DrivetrainRWD,
+	"awd":               DrivetrainAWD,
+	"all-wheel drive":   DrivetrainAWD,
+	"all wheel drive":   DrivetrainAWD,
+	"4wd":               Drivetrain4WD,
+	"4x4":               Drivetrain4WD,
+	"4-wheel drive":     Drivetrain4WD,
+	"four-wheel drive":  Drivetrain4WD,
+	"four wheel drive":  Drivetrain4WD,
+}
+
+// separates the options in values like "FWD/AWD", "FWD, AWD" and "RWD or AWD"
+var optionSeparatorRegex = regexp.MustCompile(`(?i)\s*(?:/|,|\bor\b)\s*`)
+
+// parseDrivetrains splits a drivetrain into its canonical drivetrains, without duplicates
+func parseDrivetrains(s string) ([]string, error) {
+	var parsed []string
+	for _, option := range optionSeparatorRegex.Split(strings.TrimSpace(s), -1) {
+		if option == "" {
+			continue
+		}
+		drivetrain, ok := drivetrainAliases[strings.ToLower(option)]
+		if !ok {
+			return nil, fmt.Errorf("unrecognized drivetrain %q in %q", option, s)
+		}
+		if !containsString(parsed, drivetrain) {
+			parsed = append(parsed, drivetrain)
+		}
+	}
+	return parsed, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanTransmission parses the transmission type (i.e. "7-speed PDK", "CVT", "Manual, Automatic" or
+// "6-speed manual, 6-speed automatic") into the transmission options the car is offered with.
+// When the transmissions are already given (i.e. through the API) their kinds are canonicalized
+// instead and the raw value filled in if missing. Options that aren't transmissions at all
+// (i.e. "4-motor all-wheel drive") are logged and skipped
+func cleanTransmission(c *CarRecord) error {
+	car := c.Car
+	if len(car.Transmissions) > 0 {
+		labels := make([]string, len(car.Transmissions))
+		for i, transmission := range car.Transmissions {
+			for _, kind := range transmissionKinds {
+				if strings.EqualFold(kind, transmission.Kind) {
+					car.Transmissions[i].Kind = kind
+				}
+			}
+			labels[i] = car.Transmissions[i].String()
+		}
+		if car.TransmissionType == "" {
+			car.TransmissionType = strings.Join(labels, ", ")
+		}
 		return nil
 	}
 
-	// the year range may have spacing between the hyphen and years (i.e. 2008 - 2012)
-	// we eliminate those then split about the hyphen to produce a two-element array
-	// (i.e. [2008, 2012])
-	yearRange := strings.Split(trimmedYearRange, "-")
+	car.Transmissions = nil
+	for _, option := range optionSeparatorRegex.Split(strings.TrimSpace(car.TransmissionType), -1) {
+		if option == "" {
+			continue
+		}
+		transmission, err := parseTransmission(option)
+		if err != nil {
+			car.warn(columnTransmissionType, "%s, skipping it", err)
+			continue
+		}
+		car.Transmissions = append(car.Transmissions, *transmission)
+	}
+	return nil
+}
+
+// transmissionKindKeywords maps (lowercased) keywords to the kind of transmission they describe.
+// They're checked in order since i.e. "automated manual" and "dual-clutch automatic" aren't manuals
+// nor plain automatics
+var transmissionKindKeywords = []struct {
+	keyword *regexp.Regexp
+	kind    string
+}{
+	{wordRegexp("cvt"), TransmissionCVT},
+	{wordRegexp("ecvt"), TransmissionCVT},
+	{wordRegexp("continuously variable"), TransmissionCVT},
+	{wordRegexp("dual-clutch"), TransmissionDCT},
+	{wordRegexp("dual clutch"), TransmissionDCT},
+	{wordRegexp("multi-clutch"), TransmissionDCT},
+	{wordRegexp("dct"), TransmissionDCT},
+	{wordRegexp("dsg"), TransmissionDCT},
+	{wordRegexp("pdk"), TransmissionDCT},
+	{wordRegexp("amt"), TransmissionAutomatic},
+	{wordRegexp("automated manual"), TransmissionAutomatic},
+	{wordRegexp("auto-shift manual"), TransmissionAutomatic},
+	{wordRegexp("manual"), TransmissionManual},
+	{wordRegexp("automatic"), TransmissionAutomatic},
+	{wordRegexp("tiptronic"), TransmissionAutomatic},
+	{wordRegexp("at"), TransmissionAutomatic},
+	{wordRegexp("direct drive"), TransmissionAutomatic},
+	{wordRegexp("electric"), TransmissionAutomatic},
+}
+
+// transmissionLabels maps the (lowercased) branded transmission names to how they're written
+var transmissionLabels = []struct {
+	keyword *regexp.Regexp
+	label   string
+}{
+	{wordRegexp("koenigsegg direct drive"), "Koenigsegg Direct Drive"},
+	{wordRegexp("tiptronic s"), "Tiptronic S"},
+	{wordRegexp("tiptronic"), "Tiptronic"},
+	{wordRegexp("pdk"), "PDK"},
+	{wordRegexp("dsg"), "DSG"},
+	{wordRegexp("amt"), "AMT"},
+	{wordRegexp("ecvt"), "eCVT"},
+}
+
+// matches the gear count in "7-speed", "4 speed" and "Single-speed"
+var gearsRegex = regexp.MustCompile(`(?i)\b(\d+|single)[- ]speed\b`)
+
+// parseTransmission parses a single transmission option (i.e. "7-speed dual-clutch automatic")
+func parseTransmission(option string) (*Transmission, error) {
+	lowerOption := strings.ToLower(option)
+	transmission := &Transmission{}
+
+	for _, k := range transmissionKindKeywords {
+		if k.keyword.MatchString(lowerOption) {
+			transmission.Kind = k.kind
+			break
+		}
+	}
+	if transmission.Kind == "" {
+		return nil, fmt.Errorf("unrecognized transmission %q", option)
+	}
+
+	for _, l := range transmissionLabels {
+		if l.keyword.MatchString(lowerOption) {
+			transmission.Label = l.label
+			break
+		}
+	}
+
+	if match := gearsRegex.FindStringSubmatch(lowerOption); match != nil {
+		gears := 1
+		if match[1] != "single" {
+			gears, _ = strconv.Atoi(match[1])
+		}
+		transmission.Gears = &gears
+	}
+	return transmission, nil
+}
+
+// wordRegexp matches the keyword only as whole words so that i.e. "at" doesn't match "automatic"
+func wordRegexp(keyword string) *regexp.Regexp {
+	return regexp.MustCompile(`\b` + regexp.QuoteMeta(keyword) + `\b`)
+}
+
+// cleanEngine decomposes the engine type (i.e. "6.5L V12", "Turbocharged inline-4", "H4", "Gas, Diesel"
+// or "2.0L turbocharged petrol") into its displacements (in litres), cylinder layouts, aspirations
+// and fuels. Cars can be offered with several engines so each is a set of the values found
+func cleanEngine(c *CarRecord) error {
+	car := c.Car
+	car.EngineDisplacements, car.EngineLayouts, car.EngineAspirations, car.EngineFuels = nil, nil, nil, nil
+
+	engine := strings.ToLower(car.EngineType)
+	for _, match := range displacementRegex.FindAllStringSubmatch(engine, -1) {
+		litres, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			log.Error("There was an issue cleaning the Engine Type", "engineType", car.EngineType, "err", err)
+			return err
+		}
+		if !containsFloat(car.EngineDisplacements, litres) {
+			car.EngineDisplacements = append(car.EngineDisplacements, litres)
+		}
+	}
+
+	car.EngineLayouts = matchKeywords(engine, engineLayoutKeywords)
+	car.EngineAspirations = matchKeywords(engine, engineAspirationKeywords)
+	// an engine paired with electric motors (i.e. "Twin-turbocharged V8 + 3 electric motors") is a hybrid
+	car.EngineFuels = matchKeywords(electricMotorRegex.ReplaceAllString(engine, "hybrid"), engineFuelKeywords)
+	return nil
+}
+
+func cleanCylinders(c *CarRecord) error {
 	car := c.Car
-	car.StartYear, err = strconv.Atoi(yearRange[0]); if err != nil {
-		log.Error("There was an issue cleaning the Model Year Range for the starting year", "err", err)
+	car.Cylinders = parseCounts(car, columnNumberOfCylinders, car.NumberofCylinders, 0, maxCylinders)
+
+	// the engine type often states the cylinder count too (i.e. "3-cylinder" or "V6/V8")
+	var engineCylinders []int64
+	for _, match := range engineCylindersRegex.FindAllStringSubmatch(strings.ToLower(car.EngineType), -1) {
+		cylinders, _ := strconv.ParseInt(match[1]+match[2]+match[3], 10, 64)
+		engineCylinders = append(engineCylinders, cylinders)
+	}
+
+	// engines with a layout (i.e. "Inline-4 EV") have cylinders even when said to be electric
+	electric := len(car.EngineFuels) == 1 && car.EngineFuels[0] == FuelElectric && len(car.EngineLayouts) == 0
+	for _, cylinders := range car.Cylinders {
+		if electric && cylinders > 0 {
+			car.warn(columnNumberOfCylinders, "cylinder count %d given for an electric engine", cylinders)
+		} else if len(engineCylinders) > 0 && !containsInt(engineCylinders, cylinders) {
+			car.warn(columnNumberOfCylinders, "cylinder count %d doesn't match engine type %q", cylinders, car.EngineType)
+		}
+	}
+	return nil
+}
+
+func cleanDoors(c *CarRecord) error {
+	car := c.Car
+	car.Doors = parseCounts(car, columnNumberOfDoors, car.NumberOfDoors, minDoors, maxDoors)
+	return nil
+}
+
+const (
+	maxCylinders = 16
+	minDoors     = 2
+	maxDoors     = 8
+)
+
+var (
+	countRegex = regexp.MustCompile(`\d+`)
+	// matches the cylinder count in "3-cylinder", "V6", "I4", "W12", "H4" and "inline-6"
+	engineCylindersRegex = regexp.MustCompile(`\b(\d+)-cylinder\b|\b[vwhi]-?(\d+)\b|\b(?:inline|straight|flat)[- ]?(\d+)\b`)
+)
+
+// parseCounts parses counts like "4", "2/4", "4 or 6", "6-8", "V6" and "4 doors" into a
+// sorted set. A range only counts its ends since "6-8" means a V6 or a V8. Counts outside
+// of min and max (i.e. a cylinder count of 2006) are dropped and warned about
+func parseCounts(car *Car, column, s string, min, max int64) []int64 {
+	var counts []int64
+	for _, match := range countRegex.FindAllString(s, -1) {
+		count, err := strconv.ParseInt(match, 10, 64)
+		if err != nil || count < min || count > max {
+			car.warn(column, "implausible value %s dropped", match)
+			continue
+		}
+		if !containsInt(counts, count) {
+			counts = append(counts, count)
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i] < counts[j] })
+	return counts
+}
+
+func containsInt(values []int64, value int64) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// manufacturerIndex maps the (normalized) names and aliases of manufacturers to their ID
+type manufacturerIndex map[string]int
+
+func newManufacturerIndex(manufacturers []*Manufacturer) manufacturerIndex {
+	index := manufacturerIndex{}
+	for _, manufacturer := range manufacturers {
+		index[manufacturerKey(manufacturer.Name)] = manufacturer.ID
+		for _, alias := range manufacturer.Aliases {
+			index[manufacturerKey(alias)] = manufacturer.ID
+		}
+	}
+	return index
+}
+
+// resolve sets the ID of the manufacturer of the car's company, warning about unknown companies
+func (i manufacturerIndex) resolve(car *Car) {
+	car.ManufacturerID = nil
+	if strings.TrimSpace(car.Company) == "" {
+		return
+	}
+
+	id, ok := i[manufacturerKey(car.Company)]
+	if !ok {
+		car.warn(columnCompany, "unknown manufacturer %q", car.Company)
+		return
+	}
+	car.ManufacturerID = &id
+}
+
+// manufacturerKey ignores casing and spacing so that i.e. "Tata  motors" matches "Tata Motors"
+func manufacturerKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// warn records a data-quality issue found in the given column of the car
+func (c *Car) warn(column, format string, args ...any) {
+	issue := Issue{Column: column, Message: fmt.Sprintf(format, args...)}
+	log.Warn("Data-quality issue found", "car", c.String(), "column", column, "issue", issue.Message)
+	c.Warnings = append(c.Warnings, issue)
+}
+
+// cleanBodyType maps the body type (i.e. "Coupe, Convertible", "City car" or "Pickup Truck") to
+// the body styles of the vocabulary. Like drivetrains, structured body types are canonicalized
+// instead and values that aren't body styles (i.e. "4-seater") are warned about and left empty
+func cleanBodyType(c *CarRecord) error {
+	car := c.Car
+	if len(car.BodyTypes) > 0 {
+		names := make([]string, len(car.BodyTypes))
+		for i, bodyType := range car.BodyTypes {
+			if style := bodyStyle(bodyType); style != nil {
+				car.BodyTypes[i] = style.Slug
+				names[i] = style.Name
+			} else {
+				names[i] = bodyType
+			}
+		}
+		if car.BodyType == "" {
+			car.BodyType = strings.Join(names, ", ")
+		}
+		return nil
+	}
+
+	car.BodyTypes = matchKeywords(accentReplacer.Replace(strings.ToLower(car.BodyType)), bodyStyleKeywords)
+	if car.BodyType != "" && len(car.BodyTypes) == 0 {
+		car.warn(columnBodyType, "unrecognized body type %q, leaving it empty", car.BodyType)
+	}
+	return nil
+}
+
+// accentReplacer folds the accents found in the dataset (i.e. "Coupé") since \b doesn't treat
+// them as word characters
+var accentReplacer = strings.NewReplacer("é", "e")
+
+// bodyStyleKeywords matches the names and synonyms of each body style as whole words. A
+// "minivan" isn't a "van" since there's no word boundary within it
+var bodyStyleKeywords = func() []keyword {
+	var keywords []keyword
+	for _, style := range bodyStyles {
+		for _, name := range append([]string{style.Name}, style.Synonyms...) {
+			keywords = append(keywords, keyword{wordRegexp(accentReplacer.Replace(strings.ToLower(name))), style.Slug})
+		}
+	}
+	return keywords
+}()
+
+// keyword pairs a pattern with the value it stands for
+type keyword struct {
+	pattern *regexp.Regexp
+	value   string
+}
+
+// matchKeywords returns the values of every keyword found in text, without duplicates
+func matchKeywords(text string, keywords []keyword) []string {
+	var values []string
+	for _, k := range keywords {
+		if k.pattern.MatchString(text) && !containsString(values, k.value) {
+			values = append(values, k.value)
+		}
+	}
+	return values
+}
+
+var (
+	// matches "6.5L" and "2.0 L"
+	displacementRegex  = regexp.MustCompile(`(\d+(?:\.\d+)?)\s?l\b`)
+	electricMotorRegex = regexp.MustCompile(`\belectric motors?\b`)
+
+	engineLayoutKeywords = []keyword{
+		{regexp.MustCompile(`\bv-?\d+\b|\bhemi\b`), EngineLayoutV},
+		{regexp.MustCompile(`\b(?:inline|straight)[- ]?\d+\b|\bi\d+\b`), EngineLayoutInline},
+		{regexp.MustCompile(`\bflat-?\d+\b|\bh\d+\b|\bboxer\b`), EngineLayoutFlat},
+		{regexp.MustCompile(`\bw\d+\b`), EngineLayoutW},
+	}
+	engineAspirationKeywords = []keyword{
+		{wordRegexp("naturally aspirated"), AspirationNatural},
+		{regexp.MustCompile(`turbo`), AspirationTurbo},
+		{regexp.MustCompile(`supercharg`), AspirationSupercharged},
+	}
+	engineFuelKeywords = []keyword{
+		{regexp.MustCompile(`\b(?:gas|gasoline|petrol)\b`), FuelPetrol},
+		{wordRegexp("diesel"), FuelDiesel},
+		{wordRegexp("hybrid"), FuelHybrid},
+		{regexp.MustCompile(`\belectric\b|\bev\b|\bfuel cell\b`), FuelElectric},
+	}
+)
+
+func containsFloat(values []float64, value float64) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanPrice parses the price (i.e. "$366,712", "Starting at $42,650", "$26,000-$45,000",
+// "14.69 lakhs" or "₹10.55 Lakh - ₹16.78 Lakh") into its currency, min/max amounts in
+// minor units (cents, pence, paise) and whether it's only a starting price. Prices that
+// aren't known ("N/A" or blank) are left null
+func cleanPrice(c *CarRecord) error {
+	car := c.Car
+	car.PriceCurrency, car.PriceMin, car.PriceMax, car.PriceIsStarting = "", nil, nil, false
+
+	price := strings.TrimSpace(car.Price)
+	if price == "" || strings.EqualFold(price, "N/A") {
+		return nil
+	}
+	lowerPrice := strings.ToLower(price)
+
+	currency := priceCurrency(lowerPrice)
+	if currency == "" {
+		err := fmt.Errorf("unknown currency in price %q", car.Price)
+		log.Error("There was an issue cleaning the Price", "price", car.Price, "err", err)
 		return err
 	}
 
-	// catch the case where there's no ending year
-	if len(yearRange) > 1 {
-		endYear := yearRange[1]	
-		car.EndYear, err = checkEndYear(endYear); if err != nil {
-			log.Error("There was an issue cleaning the Model Year Range for the ending year", "err", err)
+	amounts := priceRegex.FindAllStringSubmatch(lowerPrice, -1)
+	if len(amounts) == 0 {
+		err := fmt.Errorf("no amount found in price %q", car.Price)
+		log.Error("There was an issue cleaning the Price", "price", car.Price, "err", err)
+		return err
+	}
+
+	// each amount has its own magnitude word (i.e. "₹90 Lakh - ₹1.2 Crore"), or takes the one
+	// of the amount after it when it has none (i.e. "Rs. 12.11 - 16.86 Lakh")
+	multipliers := make([]float64, len(amounts))
+	multiplier := 1.0
+	for i := len(amounts) - 1; i >= 0; i-- {
+		if word := amounts[i][2]; word != "" {
+			multiplier = priceMultiplier(word)
+		}
+		multipliers[i] = multiplier
+	}
+
+	var min, max int64
+	for i, amount := range amounts {
+		n, err := parseNumber(amount[1])
+		if err != nil {
+			log.Error("There was an issue cleaning the Price", "price", car.Price, "err", err)
 			return err
 		}
+		minor := int64(math.Round(n * multipliers[i] * 100))
+		if i == 0 || minor < min {
+			min = minor
+		}
+		if i == 0 || minor > max {
+			max = minor
+		}
 	}
 
+	car.PriceCurrency = currency
+	car.PriceMin, car.PriceMax = &min, &max
+	car.PriceIsStarting = strings.HasPrefix(lowerPrice, "starting at")
 	return nil
 }
 
-// checkEndYear checks if the ending year is "present"; if so replace "present" with the current year
-func checkEndYear(endYear string) (int, error) {
-	endYear = strings.ToLower(endYear)
-	if endYear == "present" || endYear == "" {
-		return time.Now().Year(), nil
+// priceCurrency returns the ISO 4217 code for the currency used in the (lowercased) price
+func priceCurrency(price string) string {
+	switch {
+	case strings.Contains(price, "₹"), strings.HasPrefix(price, "rs"), strings.Contains(price, "lakh"), strings.Contains(price, "crore"):
+		return "INR"
+	case strings.Contains(price, "£"):
+		return "GBP"
+	case strings.Contains(price, "€"):
+		return "EUR"
+	case strings.Contains(price, "$"):
+		return "USD"
+	default:
+		return ""
 	}
-	return strconv.Atoi(endYear)
 }
 
-// TODO figure out if we even want to clean the price
-// func cleanPrice(p string) {}
\ No newline at end of file
+// priceMultipliers are the magnitude words used in prices along with their factor.
+// Lakh (100,000) and crore (10,000,000) are used for Indian prices
+var priceMultipliers = []struct {
+	word   string
+	factor float64
+}{
+	{"lakh", 1e5},
+	{"crore", 1e7},
+	{"million", 1e6},
+}
+
+// a number (possibly with thousands separators or decimals) within a (lowercased) price,
+// along with the magnitude word following it if any
+var priceRegex = regexp.MustCompile(`(\d[\d,]*(?:\.\d+)?)(?:\s*(lakh|crore|million)s?\b)?`)
+
+// priceMultiplier returns the factor of a magnitude word matched by priceRegex
+func priceMultiplier(word string) float64 {
+	for _, multiplier := range priceMultipliers {
+		if multiplier.word == word {
+			return multiplier.factor
+		}
+	}
+	return 1
+}
\ No newline at end of file
@@ -2,12 +2,14 @@ package main
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"testing"
 	"time"
 
-	"github.com/gocarina/gocsv"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -92,15 +94,15 @@ func TestCsvReader(t *testing.T) {
 			// Create a mock CarDB implementation for testing
 			mockDB := &MockDB{}			
 
-			// Use the gocsv package to write the array of CarRecords to the CSV file
-			err = gocsv.MarshalFile(&tc.fileContent, file)
+			// Write the array of CarRecords to the CSV file
+			err = marshalCarRecords(tc.fileContent, file)
 			assert.NoError(t, err)
 			
 			// Need to move cursor back to the top if we're expecting more than just headers
 			if !tc.expectedError {
 				file.Seek(1,0)
 			}
-			err = CsvReader(file, mockDB)
+			_, err = CsvReader(file, mockDB, 0)
 
 			// Assert the expected error
 			if tc.expectedError {
@@ -111,7 +113,7 @@ func TestCsvReader(t *testing.T) {
 
 			// Assert the expected years
 			ctx := context.TODO()
-			cars, err = mockDB.GetCars(ctx, nil)
+			cars, err = mockDB.GetCars(ctx, nil, nil)
 			assert.NoError(t, err)
 			
 			var actualYears []int
@@ -134,11 +136,802 @@ func TestCsvReader(t *testing.T) {
 func addCSVHeaders(file *os.File) error {
 	carRecord := []*CarRecord{}
 
-	// Use the gocsv package to write the array of CarRecords to the CSV file
-	if err := gocsv.MarshalFile(carRecord, file); err != nil {
+	// Write the array of CarRecords to the CSV file
+	if err := marshalCarRecords(carRecord, file); err != nil {
 		fmt.Println("Failed to write headers to CSV file:", err)
 		return err
 	}
 	return nil
 }
 
+
+func TestCleanHorsepower(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+
+	testCases := []struct {
+		name           string
+		horsepower     string
+		expectedMin    *int
+		expectedMax    *int
+		expectedUnit   string
+		expectedRPMMin *int
+		expectedRPMMax *int
+		expectedError  bool
+	}{
+		{name: "Single Value", horsepower: "789 hp", expectedMin: intPtr(789), expectedMax: intPtr(789), expectedUnit: "hp"},
+		{name: "Thousands Separator", horsepower: "1,001 hp", expectedMin: intPtr(1001), expectedMax: intPtr(1001), expectedUnit: "hp"},
+		{name: "Brake Horsepower", horsepower: "170 bhp", expectedMin: intPtr(170), expectedMax: intPtr(170), expectedUnit: "bhp"},
+		{name: "With RPM", horsepower: "117 bhp @ 3500 rpm", expectedMin: intPtr(117), expectedMax: intPtr(117), expectedUnit: "bhp", expectedRPMMin: intPtr(3500), expectedRPMMax: intPtr(3500)},
+		{name: "With RPM Band", horsepower: "300 hp @ 5500-6500 rpm", expectedMin: intPtr(300), expectedMax: intPtr(300), expectedUnit: "hp", expectedRPMMin: intPtr(5500), expectedRPMMax: intPtr(6500)},
+		{name: "Bare Range", horsepower: "200-308", expectedMin: intPtr(200), expectedMax: intPtr(308), expectedUnit: "hp"},
+		{name: "Range With Units", horsepower: "147 hp - 201 hp", expectedMin: intPtr(147), expectedMax: intPtr(201), expectedUnit: "hp"},
+		{name: "Conversions In Parentheses", horsepower: "160 PS (158 bhp - 118 kW)", expectedMin: intPtr(160), expectedMax: intPtr(160), expectedUnit: "PS"},
+		{name: "Empty", horsepower: ""},
+		{name: "Not Available", horsepower: "N/A"},
+		{name: "No Number", horsepower: "fast", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := &CarRecord{Car: &Car{Horsepower: tc.horsepower}}
+			err := cleanHorsepower(record)
+
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedMin, record.HorsepowerMin)
+			assert.Equal(t, tc.expectedMax, record.HorsepowerMax)
+			assert.Equal(t, tc.expectedUnit, record.HorsepowerUnit)
+			assert.Equal(t, tc.expectedRPMMin, record.HorsepowerRPMMin)
+			assert.Equal(t, tc.expectedRPMMax, record.HorsepowerRPMMax)
+			assert.Equal(t, tc.horsepower, record.Horsepower)
+		})
+	}
+}
+
+func TestCleanTorque(t *testing.T) {
+	floatPtr := func(f float64) *float64 { return &f }
+	intPtr := func(i int) *int { return &i }
+
+	testCases := []struct {
+		name          string
+		torque        string
+		expectedMinNm *float64
+		expectedMaxNm *float64
+		expectedMinLb *float64
+		expectedMaxLb *float64
+		expectedRPM   []*int
+		expectedError bool
+	}{
+		{name: "Pound Feet", torque: "530 lb-ft", expectedMinNm: floatPtr(718.6), expectedMaxNm: floatPtr(718.6), expectedMinLb: floatPtr(530), expectedMaxLb: floatPtr(530)},
+		{name: "Newton Metres", torque: "350 Nm", expectedMinNm: floatPtr(350), expectedMaxNm: floatPtr(350), expectedMinLb: floatPtr(258.1), expectedMaxLb: floatPtr(258.1)},
+		{name: "RPM Band", torque: "250 Nm @ 1500-2000 rpm", expectedMinNm: floatPtr(250), expectedMaxNm: floatPtr(250), expectedMinLb: floatPtr(184.4), expectedMaxLb: floatPtr(184.4), expectedRPM: []*int{intPtr(1500), intPtr(2000)}},
+		{name: "Single RPM With Conversion", torque: "377.0 Nm (278 lb. ft) at 4000 rpm", expectedMinNm: floatPtr(377), expectedMaxNm: floatPtr(377), expectedMinLb: floatPtr(278.1), expectedMaxLb: floatPtr(278.1), expectedRPM: []*int{intPtr(4000), intPtr(4000)}},
+		{name: "Bare Range", torque: "191-369", expectedMinNm: floatPtr(259), expectedMaxNm: floatPtr(500.3), expectedMinLb: floatPtr(191), expectedMaxLb: floatPtr(369)},
+		{name: "Empty", torque: ""},
+		{name: "No Number", torque: "lots", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := &CarRecord{Car: &Car{Torque: tc.torque}}
+			err := cleanTorque(record)
+
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedMinNm, record.TorqueMinNm)
+			assert.Equal(t, tc.expectedMaxNm, record.TorqueMaxNm)
+			assert.Equal(t, tc.expectedMinLb, record.TorqueMinLbFt)
+			assert.Equal(t, tc.expectedMaxLb, record.TorqueMaxLbFt)
+			if tc.expectedRPM == nil {
+				assert.Nil(t, record.TorqueRPMMin)
+				assert.Nil(t, record.TorqueRPMMax)
+			} else {
+				assert.Equal(t, tc.expectedRPM[0], record.TorqueRPMMin)
+				assert.Equal(t, tc.expectedRPM[1], record.TorqueRPMMax)
+			}
+		})
+	}
+}
+
+func TestCleanPrice(t *testing.T) {
+	int64Ptr := func(i int64) *int64 { return &i }
+
+	testCases := []struct {
+		name             string
+		price            string
+		expectedCurrency string
+		expectedMin      *int64
+		expectedMax      *int64
+		expectedStarting bool
+		expectedError    bool
+	}{
+		{name: "Dollars", price: "$366,712", expectedCurrency: "USD", expectedMin: int64Ptr(36671200), expectedMax: int64Ptr(36671200)},
+		{name: "Starting Price", price: "Starting at $42,650", expectedCurrency: "USD", expectedMin: int64Ptr(4265000), expectedMax: int64Ptr(4265000), expectedStarting: true},
+		{name: "Range", price: "$26,000-$45,000", expectedCurrency: "USD", expectedMin: int64Ptr(2600000), expectedMax: int64Ptr(4500000)},
+		{name: "Millions", price: "$1.5 million", expectedCurrency: "USD", expectedMin: int64Ptr(150000000), expectedMax: int64Ptr(150000000)},
+		{name: "Pounds", price: "Starting at £24,495", expectedCurrency: "GBP", expectedMin: int64Ptr(2449500), expectedMax: int64Ptr(2449500), expectedStarting: true},
+		{name: "Euros", price: "Starting at €130,000", expectedCurrency: "EUR", expectedMin: int64Ptr(13000000), expectedMax: int64Ptr(13000000), expectedStarting: true},
+		{name: "Lakhs", price: "14.69 lakhs", expectedCurrency: "INR", expectedMin: int64Ptr(146900000), expectedMax: int64Ptr(146900000)},
+		{name: "Rupee Lakh Range", price: "₹10.55 Lakh - ₹16.78 Lakh", expectedCurrency: "INR", expectedMin: int64Ptr(105500000), expectedMax: int64Ptr(167800000)},
+		{name: "Rs Lakh Range", price: "Rs. 12.11 - 16.86 Lakh", expectedCurrency: "INR", expectedMin: int64Ptr(121100000), expectedMax: int64Ptr(168600000)},
+		{name: "Mixed Magnitudes", price: "₹90 Lakh - ₹1.2 Crore", expectedCurrency: "INR", expectedMin: int64Ptr(900000000), expectedMax: int64Ptr(1200000000)},
+		{name: "Crores", price: "Rs. 2.5 - 3 Crores", expectedCurrency: "INR", expectedMin: int64Ptr(2500000000), expectedMax: int64Ptr(3000000000)},
+		{name: "Not Available", price: "N/A"},
+		{name: "Empty", price: ""},
+		{name: "Unknown Currency", price: "42,650", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := &CarRecord{Car: &Car{Price: tc.price}}
+			err := cleanPrice(record)
+
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedCurrency, record.PriceCurrency)
+			assert.Equal(t, tc.expectedMin, record.PriceMin)
+			assert.Equal(t, tc.expectedMax, record.PriceMax)
+			assert.Equal(t, tc.expectedStarting, record.PriceIsStarting)
+		})
+	}
+}
+
+func TestCleanFuelEconomy(t *testing.T) {
+	floatPtr := func(f float64) *float64 { return &f }
+
+	testCases := []struct {
+		name               string
+		fuelEconomy        string
+		expectedCity       *float64
+		expectedHighway    *float64
+		expectedCombined   *float64
+		expectedSourceUnit string
+		expectedWarning    bool
+		expectedError      bool
+	}{
+		{name: "City/Highway", fuelEconomy: "13/20 mpg", expectedCity: floatPtr(18.09), expectedHighway: floatPtr(11.76), expectedSourceUnit: "mpg"},
+		{name: "Range", fuelEconomy: "17-22 mpg", expectedWarning: true},
+		{name: "Spaced Range", fuelEconomy: "17 - 22 mpg", expectedWarning: true},
+		{name: "Spaced Labelled Ranges", fuelEconomy: "16 - 20 mpg city / 22 - 26 mpg highway", expectedCity: floatPtr(14.7), expectedHighway: floatPtr(10.69), expectedSourceUnit: "mpg"},
+		{name: "Labelled", fuelEconomy: "34 mpg (city)/41 mpg (highway)", expectedCity: floatPtr(6.92), expectedHighway: floatPtr(5.74), expectedSourceUnit: "mpg"},
+		{name: "Labelled Without Unit", fuelEconomy: "20 city / 27 highway", expectedCity: floatPtr(11.76), expectedHighway: floatPtr(8.71), expectedSourceUnit: "mpg"},
+		{name: "Kilometres Per Litre", fuelEconomy: "13.1 kmpl", expectedCombined: floatPtr(7.63), expectedSourceUnit: "kmpl"},
+		{name: "Combined", fuelEconomy: "18 mpg combined", expectedCombined: floatPtr(13.07), expectedSourceUnit: "mpg"},
+		{name: "Litres Per 100km", fuelEconomy: "16.4 l/100 km - 17 mpg UK - 14 mpg US (Average), 0 to 100 km/h (62mph) in 4.2 seconds", expectedCombined: floatPtr(16.4), expectedSourceUnit: "L/100km"},
+		{name: "Electric Range", fuelEconomy: "310 miles"},
+		{name: "Not Available", fuelEconomy: "N/A"},
+		{name: "No Number", fuelEconomy: "good", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := &CarRecord{Car: &Car{FuelEconomy: tc.fuelEconomy}}
+			err := cleanFuelEconomy(record)
+
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedCity, record.FuelEconomyCity)
+			assert.Equal(t, tc.expectedHighway, record.FuelEconomyHighway)
+			assert.Equal(t, tc.expectedCombined, record.FuelEconomyCombined)
+			assert.Equal(t, tc.expectedSourceUnit, record.FuelEconomySourceUnit)
+			assert.Equal(t, tc.expectedWarning, len(record.Warnings) > 0)
+		})
+	}
+}
+
+func TestConvertFuelEconomy(t *testing.T) {
+	record := &CarRecord{Car: &Car{FuelEconomy: "13/20 mpg"}}
+	assert.NoError(t, cleanFuelEconomy(record))
+
+	record.ConvertFuelEconomy(fuelEconomyUnitMPG)
+	assert.Equal(t, 13.0, *record.FuelEconomyCity)
+	assert.Equal(t, 20.0, *record.FuelEconomyHighway)
+	assert.Equal(t, fuelEconomyUnitMPG, record.FuelEconomyUnit)
+
+	record.ConvertFuelEconomy(fuelEconomyUnitKmpl)
+	assert.Equal(t, 5.5, *record.FuelEconomyCity)
+	assert.Equal(t, 8.5, *record.FuelEconomyHighway)
+	assert.Equal(t, fuelEconomyUnitKmpl, record.FuelEconomyUnit)
+}
+
+func TestCleanDrivetrain(t *testing.T) {
+	testCases := []struct {
+		name                string
+		drivetrain          string
+		drivetrains         []string
+		expectedDrivetrain  string
+		expectedDrivetrains []string
+	}{
+		{name: "Abbreviation", drivetrain: "RWD", expectedDrivetrain: "RWD", expectedDrivetrains: []string{"RWD"}},
+		{name: "Spelled Out", drivetrain: "Rear-wheel drive", expectedDrivetrain: "Rear-wheel drive", expectedDrivetrains: []string{"RWD"}},
+		{name: "Slash Separated", drivetrain: "FWD/AWD", expectedDrivetrain: "FWD/AWD", expectedDrivetrains: []string{"FWD", "AWD"}},
+		{name: "Or Separated", drivetrain: "Front-wheel drive or all-wheel drive", expectedDrivetrain: "Front-wheel drive or all-wheel drive", expectedDrivetrains: []string{"FWD", "AWD"}},
+		{name: "Four Wheel Drive", drivetrain: "RWD / 4WD", expectedDrivetrain: "RWD / 4WD", expectedDrivetrains: []string{"RWD", "4WD"}},
+		{name: "Not A Drivetrain", drivetrain: "0-60 mph in 3 seconds", expectedDrivetrain: "0-60 mph in 3 seconds"},
+		{name: "Structured", drivetrains: []string{"awd", "FWD"}, expectedDrivetrain: "AWD/FWD", expectedDrivetrains: []string{"AWD", "FWD"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := &CarRecord{Car: &Car{Drivetrain: tc.drivetrain, Drivetrains: tc.drivetrains}}
+			assert.NoError(t, cleanDrivetrain(record))
+			assert.Equal(t, tc.expectedDrivetrain, record.Drivetrain)
+			assert.Equal(t, tc.expectedDrivetrains, record.Drivetrains)
+		})
+	}
+}
+
+func TestCleanTransmission(t *testing.T) {
+	gears := func(i int) *int { return &i }
+
+	testCases := []struct {
+		name                  string
+		transmissionType      string
+		transmissions         Transmissions
+		expectedType          string
+		expectedTransmissions Transmissions
+	}{
+		{name: "Automatic", transmissionType: "7-speed automatic", expectedType: "7-speed automatic", expectedTransmissions: Transmissions{{Kind: "automatic", Gears: gears(7)}}},
+		{name: "Branded Dual-Clutch", transmissionType: "7-speed PDK", expectedType: "7-speed PDK", expectedTransmissions: Transmissions{{Kind: "DCT", Gears: gears(7), Label: "PDK"}}},
+		{name: "CVT", transmissionType: "CVT", expectedType: "CVT", expectedTransmissions: Transmissions{{Kind: "CVT"}}},
+		{name: "Without Gears", transmissionType: "Manual, Automatic", expectedType: "Manual, Automatic", expectedTransmissions: Transmissions{{Kind: "manual"}, {Kind: "automatic"}}},
+		{name: "Several Options", transmissionType: "6-speed manual, CVT, or 7-speed dual-clutch", expectedType: "6-speed manual, CVT, or 7-speed dual-clutch", expectedTransmissions: Transmissions{{Kind: "manual", Gears: gears(6)}, {Kind: "CVT"}, {Kind: "DCT", Gears: gears(7)}}},
+		{name: "Single Speed", transmissionType: "Single-speed automatic", expectedType: "Single-speed automatic", expectedTransmissions: Transmissions{{Kind: "automatic", Gears: gears(1)}}},
+		{name: "Not A Transmission", transmissionType: "4-motor all-wheel drive", expectedType: "4-motor all-wheel drive"},
+		{name: "Structured", transmissions: Transmissions{{Kind: "Manual", Gears: gears(6)}, {Kind: "dct", Gears: gears(8), Label: "PDK"}}, expectedType: "6-speed manual, 8-speed PDK", expectedTransmissions: Transmissions{{Kind: "manual", Gears: gears(6)}, {Kind: "DCT", Gears: gears(8), Label: "PDK"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := &CarRecord{Car: &Car{TransmissionType: tc.transmissionType, Transmissions: tc.transmissions}}
+			assert.NoError(t, cleanTransmission(record))
+			assert.Equal(t, tc.expectedType, record.TransmissionType)
+			assert.Equal(t, tc.expectedTransmissions, record.Transmissions)
+		})
+	}
+}
+
+func TestCleanEngine(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		engineType            string
+		expectedDisplacements []float64
+		expectedLayouts       []string
+		expectedAspirations   []string
+		expectedFuels         []string
+	}{
+		{name: "Displacement And Layout", engineType: "6.5L V12", expectedDisplacements: []float64{6.5}, expectedLayouts: []string{"V"}},
+		{name: "Aspiration", engineType: "Turbocharged inline-4", expectedLayouts: []string{"inline"}, expectedAspirations: []string{"turbo"}},
+		{name: "Flat", engineType: "H4", expectedLayouts: []string{"flat"}},
+		{name: "Several Fuels", engineType: "Gas, Diesel", expectedFuels: []string{"petrol", "diesel"}},
+		{name: "Displacement, Aspiration And Fuel", engineType: "2.0L turbocharged petrol", expectedDisplacements: []float64{2.0}, expectedAspirations: []string{"turbo"}, expectedFuels: []string{"petrol"}},
+		{name: "Several Engines", engineType: "2.4L 4-cylinder, 2.5L turbocharged 4-cylinder, or 3.3L V6", expectedDisplacements: []float64{2.4, 2.5, 3.3}, expectedLayouts: []string{"V"}, expectedAspirations: []string{"turbo"}},
+		{name: "Naturally Aspirated", engineType: "1.2L naturally aspirated petrol", expectedDisplacements: []float64{1.2}, expectedAspirations: []string{"NA"}, expectedFuels: []string{"petrol"}},
+		{name: "Electric Motors", engineType: "Twin-turbocharged V8 + 3 electric motors", expectedLayouts: []string{"V"}, expectedAspirations: []string{"turbo"}, expectedFuels: []string{"hybrid"}},
+		{name: "W Engine", engineType: "W16", expectedLayouts: []string{"W"}},
+		{name: "Empty", engineType: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := &CarRecord{Car: &Car{EngineType: tc.engineType}}
+			assert.NoError(t, cleanEngine(record))
+			assert.Equal(t, tc.expectedDisplacements, record.EngineDisplacements)
+			assert.Equal(t, tc.expectedLayouts, record.EngineLayouts)
+			assert.Equal(t, tc.expectedAspirations, record.EngineAspirations)
+			assert.Equal(t, tc.expectedFuels, record.EngineFuels)
+		})
+	}
+}
+
+func TestCleanCylinders(t *testing.T) {
+	testCases := []struct {
+		name              string
+		cylinders         string
+		engineType        string
+		engineFuels       []string
+		expectedCylinders []int64
+		expectedWarnings  Issues
+	}{
+		{name: "Single Count", cylinders: "4", engineType: "2.0L turbocharged 4-cylinder", expectedCylinders: []int64{4}},
+		{name: "Several Counts", cylinders: "4 or 6", expectedCylinders: []int64{4, 6}},
+		{name: "Range", cylinders: "6-8", engineType: "V6/V8", expectedCylinders: []int64{6, 8}},
+		{name: "Layout", cylinders: "V6", expectedCylinders: []int64{6}},
+		{name: "Implausible Count", cylinders: "4, 5, 2006", expectedCylinders: []int64{4, 5}, expectedWarnings: Issues{{Column: "Number of Cylinders", Message: "implausible value 2006 dropped"}}},
+		{name: "Conflicts With Engine", cylinders: "1", engineType: "3-cylinder", expectedCylinders: []int64{1}, expectedWarnings: Issues{{Column: "Number of Cylinders", Message: `cylinder count 1 doesn't match engine type "3-cylinder"`}}},
+		{name: "Electric", cylinders: "0", engineType: "Electric", engineFuels: []string{"electric"}, expectedCylinders: []int64{0}},
+		{name: "Electric With Cylinders", cylinders: "1", engineType: "Fuel cell", engineFuels: []string{"electric"}, expectedCylinders: []int64{1}, expectedWarnings: Issues{{Column: "Number of Cylinders", Message: "cylinder count 1 given for an electric engine"}}},
+		{name: "Not Applicable", cylinders: "N/A"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := &CarRecord{Car: &Car{NumberofCylinders: tc.cylinders, EngineType: tc.engineType, EngineFuels: tc.engineFuels}}
+			assert.NoError(t, cleanCylinders(record))
+			assert.Equal(t, tc.expectedCylinders, record.Cylinders)
+			assert.Equal(t, tc.expectedWarnings, record.Warnings)
+		})
+	}
+}
+
+func TestCleanDoors(t *testing.T) {
+	testCases := []struct {
+		name             string
+		doors            string
+		expectedDoors    []int64
+		expectedWarnings Issues
+	}{
+		{name: "Single Count", doors: "4", expectedDoors: []int64{4}},
+		{name: "With Unit", doors: "2 doors", expectedDoors: []int64{2}},
+		{name: "Slash Separated", doors: "2/4", expectedDoors: []int64{2, 4}},
+		{name: "Range", doors: "2-4", expectedDoors: []int64{2, 4}},
+		{name: "Implausible Count", doors: "2/4/2005", expectedDoors: []int64{2, 4}, expectedWarnings: Issues{{Column: "Number of Doors", Message: "implausible value 2005 dropped"}}},
+		{name: "Empty", doors: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := &CarRecord{Car: &Car{NumberOfDoors: tc.doors}}
+			assert.NoError(t, cleanDoors(record))
+			assert.Equal(t, tc.expectedDoors, record.Doors)
+			assert.Equal(t, tc.expectedWarnings, record.Warnings)
+		})
+	}
+}
+
+func TestResolveManufacturer(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+	index := newManufacturerIndex([]*Manufacturer{
+		{ID: 1, Name: "Opel", Aliases: []string{"Opel/Vauxhall"}},
+		{ID: 2, Name: "Tata Motors", Aliases: []string{"Tata"}},
+	})
+
+	testCases := []struct {
+		name             string
+		company          string
+		expectedID       *int
+		expectedWarnings Issues
+	}{
+		{name: "Canonical Name", company: "Opel", expectedID: intPtr(1)},
+		{name: "Alias", company: "Opel/Vauxhall", expectedID: intPtr(1)},
+		{name: "Casing And Spacing", company: " tata  MOTORS", expectedID: intPtr(2)},
+		{name: "Unknown", company: "Trabant", expectedWarnings: Issues{{Column: "Company", Message: `unknown manufacturer "Trabant"`}}},
+		{name: "Empty", company: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			car := &Car{Company: tc.company}
+			index.resolve(car)
+			assert.Equal(t, tc.expectedID, car.ManufacturerID)
+			assert.Equal(t, tc.expectedWarnings, car.Warnings)
+		})
+	}
+}
+
+// TestLoadManufacturers checks that the seeded manufacturers cover every company in the dataset
+func TestLoadManufacturers(t *testing.T) {
+	manufacturers, err := LoadManufacturers("resources/manufacturers.yml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	for i, manufacturer := range manufacturers {
+		manufacturer.ID = i + 1
+	}
+	index := newManufacturerIndex(manufacturers)
+
+	file, err := os.Open("resources/Car_Models.csv")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer file.Close()
+
+	reader, err := newCSVRecordReader(CSVConfig{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	records, err := reader.Read(file)
+	if assert.NoError(t, err) {
+		for _, record := range records {
+			index.resolve(record.Car)
+			assert.NotNil(t, record.ManufacturerID, "no manufacturer for %q", record.Company)
+		}
+	}
+}
+
+func TestCleanYears(t *testing.T) {
+	year := func(i int) *int { return &i }
+
+	testCases := []struct {
+		name                 string
+		modelYearRange       string
+		expectedStartYear    int
+		expectedEndYear      *int
+		expectedInProduction bool
+		expectedError        bool
+	}{
+		{name: "Range", modelYearRange: "1954-1955", expectedStartYear: 1954, expectedEndYear: year(1955)},
+		{name: "Spaced Range", modelYearRange: "2008 - 2012", expectedStartYear: 2008, expectedEndYear: year(2012)},
+		{name: "En Dash", modelYearRange: "2015–2018", expectedStartYear: 2015, expectedEndYear: year(2018)},
+		{name: "Two-Digit End Year", modelYearRange: "2008-12", expectedStartYear: 2008, expectedEndYear: year(2012)},
+		{name: "Single Year", modelYearRange: "2022", expectedStartYear: 2022, expectedEndYear: year(2022)},
+		{name: "Present", modelYearRange: "2023-present", expectedStartYear: 2023, expectedInProduction: true},
+		{name: "Spaced Present", modelYearRange: "2018 - Present", expectedStartYear: 2018, expectedInProduction: true},
+		{name: "Onwards", modelYearRange: "2019 onwards", expectedStartYear: 2019, expectedInProduction: true},
+		{name: "Empty", modelYearRange: ""},
+		{name: "Backwards", modelYearRange: "2012-2008", expectedError: true},
+		{name: "Not A Year Range", modelYearRange: "ModelYearRange", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := &CarRecord{Car: &Car{}, ModelYearRange: tc.modelYearRange}
+			err := cleanYears(record)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedStartYear, record.StartYear)
+			assert.Equal(t, tc.expectedEndYear, record.EndYear)
+			assert.Equal(t, tc.expectedInProduction, record.InProduction)
+		})
+	}
+}
+
+func TestYearRangeContains(t *testing.T) {
+	end := 2012
+	testCases := []struct {
+		name     string
+		years    YearRange
+		year     int
+		expected bool
+	}{
+		{name: "Within Range", years: YearRange{StartYear: 2008, EndYear: &end}, year: 2010, expected: true},
+		{name: "End Year", years: YearRange{StartYear: 2008, EndYear: &end}, year: 2012, expected: true},
+		{name: "After Range", years: YearRange{StartYear: 2008, EndYear: &end}, year: 2015},
+		{name: "Before Range", years: YearRange{StartYear: 2008, EndYear: &end}, year: 2005},
+		{name: "In Production", years: YearRange{StartYear: 2008, InProduction: true}, year: 2015, expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.years.Contains(tc.year))
+		})
+	}
+}
+
+func TestCleanBodyType(t *testing.T) {
+	testCases := []struct {
+		name              string
+		bodyType          string
+		bodyTypes         []string
+		expectedBodyType  string
+		expectedBodyTypes []string
+		expectedWarnings  Issues
+	}{
+		{name: "Single Style", bodyType: "SUV", expectedBodyType: "SUV", expectedBodyTypes: []string{"suv"}},
+		{name: "Several Styles", bodyType: "Coupe, Convertible", expectedBodyType: "Coupe, Convertible", expectedBodyTypes: []string{"coupe", "convertible"}},
+		{name: "Accented", bodyType: "Coupé", expectedBodyType: "Coupé", expectedBodyTypes: []string{"coupe"}},
+		{name: "Synonym", bodyType: "Pickup Truck", expectedBodyType: "Pickup Truck", expectedBodyTypes: []string{"pickup"}},
+		{name: "Within Description", bodyType: "2-door sports car", expectedBodyType: "2-door sports car", expectedBodyTypes: []string{"sports-car"}},
+		{name: "Minivan Isn't A Van", bodyType: "Minivan", expectedBodyType: "Minivan", expectedBodyTypes: []string{"minivan"}},
+		{name: "City Car", bodyType: "City car", expectedBodyType: "City car", expectedBodyTypes: []string{"city-car"}},
+		{name: "Not A Body Type", bodyType: "4-seater", expectedBodyType: "4-seater", expectedWarnings: Issues{{Column: "Body Type", Message: `unrecognized body type "4-seater", leaving it empty`}}},
+		{name: "Structured", bodyTypes: []string{"Saloon", "wagon"}, expectedBodyType: "Sedan, Wagon", expectedBodyTypes: []string{"sedan", "wagon"}},
+		{name: "Empty", bodyType: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := &CarRecord{Car: &Car{BodyType: tc.bodyType, BodyTypes: tc.bodyTypes}}
+			assert.NoError(t, cleanBodyType(record))
+			assert.Equal(t, tc.expectedBodyType, record.BodyType)
+			assert.Equal(t, tc.expectedBodyTypes, record.BodyTypes)
+			assert.Equal(t, tc.expectedWarnings, record.Warnings)
+		})
+	}
+}
+
+func TestCsvReaderRejections(t *testing.T) {
+	file := writeTempCsv(t, `Company,Model,Horsepower,Torque,Transmission Type,Drivetrain,Fuel Economy,Number of Doors,Price,Model Year Range,Body Type,Engine Type,Number of Cylinders
+Ferrari,812 Superfast,789 hp,530 lb-ft,7-speed automatic,RWD,13/20 mpg,2,"$366,712",2018 - Present,Coupe,6.5L V12,12
+Toyota,Corolla,lots,126 lb-ft,CVT,FWD,31/40 mpg,4,"$20,000",2015 - 2020,Sedan,Inline-4,4
+`)
+
+	cars, rejectedRows = nil, nil
+	_, err := CsvReader(file, &MockDB{}, 0)
+	assert.NoError(t, err)
+
+	// the bad row doesn't stop the rest of the file from being imported
+	if assert.Len(t, cars, 1) {
+		assert.Equal(t, "812 Superfast", cars[0].Model)
+	}
+	if assert.Len(t, rejectedRows, 1) {
+		rejected := rejectedRows[0]
+		assert.Equal(t, file.Name(), rejected.SourceFile)
+		assert.Equal(t, 3, rejected.LineNumber)
+		assert.Equal(t, `Horsepower: no numeric value found in "lots"`, rejected.Error)
+
+		var record CarRecord
+		if assert.NoError(t, json.Unmarshal(rejected.Record, &record)) {
+			assert.Equal(t, "lots", record.Horsepower)
+			assert.Equal(t, "2015 - 2020", record.ModelYearRange)
+			assert.Zero(t, record.StartYear)
+		}
+	}
+}
+
+func TestCsvReaderWrongFieldCount(t *testing.T) {
+	file := writeTempCsv(t, `Company,Model,Horsepower,Torque,Transmission Type,Drivetrain,Fuel Economy,Number of Doors,Price,Model Year Range,Body Type,Engine Type,Number of Cylinders
+Ferrari,812 Superfast,789 hp,530 lb-ft,7-speed automatic,RWD,13/20 mpg,2,"$366,712",2018 - Present,Coupe,6.5L V12,12
+Toyota,Corolla,139 hp,126 lb-ft,CVT,FWD,31/40 mpg,4,$20,000,2015 - 2020,Sedan,Inline-4,4
+`)
+
+	cars, rejectedRows = nil, nil
+	_, err := CsvReader(file, &MockDB{}, 0)
+	assert.NoError(t, err)
+
+	// the row with an unquoted comma is rejected while the rest of the file is imported
+	if assert.Len(t, cars, 1) {
+		assert.Equal(t, "812 Superfast", cars[0].Model)
+	}
+	if assert.Len(t, rejectedRows, 1) {
+		assert.Equal(t, 3, rejectedRows[0].LineNumber)
+		assert.Equal(t, "wrong number of fields, expected 13 but found 14", rejectedRows[0].Error)
+	}
+}
+
+func TestCsvReaderColumnLimits(t *testing.T) {
+	file := writeTempCsv(t, `Company,Model,Horsepower,Torque,Transmission Type,Drivetrain,Fuel Economy,Number of Doors,Price,Model Year Range,Body Type,Engine Type,Number of Cylinders
+Ferrari,812 Superfast,789 hp,530 lb-ft,7-speed automatic,RWD,13/20 mpg,2,"$366,712",2018 - Present,Coupe,6.5L V12,12
+Toyota,Corolla Hybrid Limited Edition With Every Option Ticked,139 hp,126 lb-ft,CVT,FWD,31/40 mpg,4,"$20,000",2015 - 2020,Sedan,Inline-4,4
+`)
+
+	cars, rejectedRows = nil, nil
+	_, err := CsvReader(file, &MockDB{}, 0)
+	assert.NoError(t, err)
+
+	// the row the database would refuse is rejected instead of failing the import
+	if assert.Len(t, cars, 1) {
+		assert.Equal(t, "812 Superfast", cars[0].Model)
+	}
+	if assert.Len(t, rejectedRows, 1) {
+		assert.Equal(t, 3, rejectedRows[0].LineNumber)
+		assert.Equal(t, "model: longer than 50 characters", rejectedRows[0].Error)
+	}
+}
+
+func TestCsvReaderAllOrNothing(t *testing.T) {
+	file := writeTempCsv(t, `Company,Model,Horsepower,Torque,Transmission Type,Drivetrain,Fuel Economy,Number of Doors,Price,Model Year Range,Body Type,Engine Type,Number of Cylinders
+Ferrari,812 Superfast,789 hp,530 lb-ft,7-speed automatic,RWD,13/20 mpg,2,"$366,712",2018 - Present,Coupe,6.5L V12,12
+Toyota,Corolla,lots,126 lb-ft,CVT,FWD,31/40 mpg,4,"$20,000",2015 - 2020,Sedan,Inline-4,4
+BadCompany,Roadster,200 hp,191 lb-ft,6-speed manual,RWD,20/26 mpg,2,"$26,000",2015-2022,Roadster,Inline-4,4
+`)
+
+	cars, rejectedRows = nil, nil
+	_, err := CsvReader(file, &MockDB{}, 0)
+	assert.Error(t, err)
+
+	// a car that can't be stored fails the whole import, rejections included
+	assert.Empty(t, cars)
+	assert.Empty(t, rejectedRows)
+}
+
+func TestCsvReaderReimport(t *testing.T) {
+	header := "Company,Model,Horsepower,Torque,Transmission Type,Drivetrain,Fuel Economy,Number of Doors,Price,Model Year Range,Body Type,Engine Type,Number of Cylinders\n"
+	ferrari := "Ferrari,812 Superfast,789 hp,530 lb-ft,7-speed automatic,RWD,13/20 mpg,2,\"$366,712\",2018 - Present,Coupe,6.5L V12,12\n"
+	corolla := "Toyota,Corolla,139 hp,126 lb-ft,CVT,FWD,31/40 mpg,4,\"$20,000\",2015 - 2020,Sedan,Inline-4,4\n"
+	badCorolla := "Toyota,Corolla,lots,126 lb-ft,CVT,FWD,31/40 mpg,4,\"$20,000\",2015 - 2020,Sedan,Inline-4,4\n"
+	newCorolla := "Toyota,Corolla,169 hp,151 lb-ft,CVT,FWD,31/40 mpg,4,\"$21,500\",2015 - 2020,Sedan,Inline-4,4\n"
+
+	cars, rejectedRows = nil, nil
+	testCases := []struct {
+		name             string
+		content          string
+		expectedSummary  ImportSummary
+		expectedCars     int
+		expectedRejected int
+	}{
+		{
+			name:             "First Import",
+			content:          header + ferrari + badCorolla,
+			expectedSummary:  ImportSummary{Inserted: 1, Rejected: 1},
+			expectedCars:     1,
+			expectedRejected: 1,
+		},
+		{
+			name:             "Corrected Row",
+			content:          header + ferrari + corolla,
+			expectedSummary:  ImportSummary{Inserted: 1, Unchanged: 1},
+			expectedCars:     2,
+			expectedRejected: 0,
+		},
+		{
+			name:             "Same File",
+			content:          header + ferrari + corolla,
+			expectedSummary:  ImportSummary{Unchanged: 2},
+			expectedCars:     2,
+			expectedRejected: 0,
+		},
+		{
+			name:             "Changed Row",
+			content:          header + ferrari + newCorolla,
+			expectedSummary: ImportSummary{Updated: 1, Unchanged: 1, Updates: []CarUpdate{{Car: "Toyota Corolla", Fields: []string{
+				"horsepower", "horsepowerMax", "horsepowerMin", "price", "priceMax", "priceMin",
+				"torque", "torqueMaxLbFt", "torqueMaxNm", "torqueMinLbFt", "torqueMinNm",
+			}}}},
+			expectedCars:     2,
+			expectedRejected: 0,
+		},
+		{
+			name:             "Duplicate Rows",
+			content:          header + ferrari + newCorolla + ferrari,
+			expectedSummary:  ImportSummary{Unchanged: 2, Rejected: 1},
+			expectedCars:     2,
+			expectedRejected: 1,
+		},
+	}
+
+	// every import is of the same file so that its rejections replace the previous ones
+	file := writeTempCsv(t, "")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file.Truncate(0)
+			file.WriteAt([]byte(tc.content), 0)
+			file.Seek(0, 0)
+
+			summary, err := CsvReader(file, &MockDB{}, 0)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tc.expectedSummary, *summary)
+			assert.Len(t, cars, tc.expectedCars)
+			assert.Len(t, rejectedRows, tc.expectedRejected)
+		})
+	}
+
+	if assert.Len(t, rejectedRows, 1) {
+		assert.Equal(t, 4, rejectedRows[0].LineNumber)
+		assert.Equal(t, "duplicate of line 2", rejectedRows[0].Error)
+	}
+	if assert.Len(t, cars, 2) {
+		assert.Equal(t, 169, *cars[1].HorsepowerMin)
+	}
+}
+
+func TestCarDiff(t *testing.T) {
+	hp, moreHp := 139, 169
+	testCases := []struct {
+		name            string
+		old             *Car
+		new             *Car
+		expectedChanges []string
+	}{
+		{
+			name:            "Unchanged",
+			old:             &Car{ID: 1, Company: "Toyota", Model: "Corolla", HorsepowerMin: &hp, CreatedAt: time.Now()},
+			new:             &Car{Company: "Toyota", Model: "Corolla", HorsepowerMin: &hp},
+			expectedChanges: nil,
+		},
+		{
+			name:            "Empty And Missing Lists",
+			old:             &Car{Company: "Toyota", Doors: []int64{}},
+			new:             &Car{Company: "Toyota"},
+			expectedChanges: nil,
+		},
+		{
+			name:            "Changed",
+			old:             &Car{Company: "Toyota", Model: "Corolla", HorsepowerMin: &hp, Drivetrains: []string{"FWD"}},
+			new:             &Car{Company: "Toyota", Model: "Corolla", HorsepowerMin: &moreHp, Drivetrains: []string{"FWD", "AWD"}},
+			expectedChanges: []string{"horsepowerMin", "drivetrains"},
+		},
+		{
+			name:            "Quality Warnings",
+			old:             &Car{Company: "Toyota"},
+			new:             &Car{Company: "Toyota", Warnings: Issues{{Column: columnCompany, Message: "unknown manufacturer"}}},
+			expectedChanges: []string{"qualityWarnings"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			changes, err := tc.old.Diff(tc.new)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			var fields []string
+			for field := range changes {
+				fields = append(fields, field)
+			}
+			assert.ElementsMatch(t, tc.expectedChanges, fields)
+		})
+	}
+}
+
+// writeTempCsv writes the content to a temporary file that's removed once the test is done
+// marshalCarRecords writes the records as CSV, under the headers of the Kaggle dataset
+func marshalCarRecords(records []*CarRecord, w io.Writer) error {
+	writer := csv.NewWriter(w)
+	header := make([]string, len(csvFields))
+	for i, field := range csvFields {
+		header[i] = field.header
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, record := range records {
+		row := make([]string, len(csvFields))
+		for i, field := range csvFields {
+			row[i] = *field.value(record)
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeTempCsv(t *testing.T, content string) *os.File {
+	file, err := os.CreateTemp("", "cars-*.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		file.Close()
+		os.Remove(file.Name())
+	})
+
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	file.Seek(0, 0)
+	return file
+}
+
+func TestDryRun(t *testing.T) {
+	file := writeTempCsv(t, `Company,Model,Horsepower,Torque,Transmission Type,Drivetrain,Fuel Economy,Number of Doors,Price,Model Year Range,Body Type,Engine Type,Number of Cylinders
+Ferrari,812 Superfast,789 hp,530 lb-ft,7-speed automatic,RWD,13/20 mpg,2,"$366,712",2018 - Present,Coupe,6.5L V12,12
+Toyota,Corolla,lots,126 lb-ft,CVT,FWD,31/40 mpg,4,"$20,000",sometime,Sedan,Inline-4,4
+Chevrolet,Colorado,200 hp,191 lb-ft,6-speed automatic,RWD/4WD,20/26 mpg,2/4,"$26,000",2015-2022,Truck,Gas,"4, 5, 2006"
+`)
+
+	manufacturers := []*Manufacturer{{ID: 1, Name: "Ferrari"}, {ID: 2, Name: "Toyota"}, {ID: 3, Name: "Chevrolet"}}
+	report, err := DryRun(file, csvRecordReader{}, manufacturers)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, 3, report.Rows)
+	assert.Equal(t, 2, report.Valid)
+	assert.Equal(t, 1, report.Invalid)
+	assert.Equal(t, 1, report.Warnings)
+	if assert.Len(t, report.Issues, 2) {
+		invalid := report.Issues[0]
+		assert.Equal(t, 3, invalid.Line)
+		assert.Equal(t, "Toyota Corolla", invalid.Car)
+		if assert.Len(t, invalid.Errors, 2) {
+			assert.Equal(t, "Model Year Range", invalid.Errors[0].Column)
+			assert.Equal(t, "Horsepower", invalid.Errors[1].Column)
+		}
+
+		warned := report.Issues[1]
+		assert.Equal(t, 4, warned.Line)
+		assert.Empty(t, warned.Errors)
+		assert.Equal(t, Issues{{Column: "Number of Cylinders", Message: "implausible value 2006 dropped"}}, warned.Warnings)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at a car of a listing, by the values the listing is sorted by, so that the
+// next (or previous) page starts right after (or before) it whatever was added or removed in
+// between. It's given to clients as an opaque string
+type Cursor struct {
+	Sort []SortField `json:"sort,omitempty"`
+	// the car's values for the sort keys, ending with its ID
+	Values []any `json:"values"`
+	// whether the cars before the one pointed at are wanted rather than the ones after it
+	Before bool `json:"before,omitempty"`
+}
+
+// newCursor returns a cursor pointing at the car of a listing sorted by the fields
+func newCursor(car *Car, sort []SortField, before bool) *Cursor {
+	keys := sortKeys(sort)
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		values[i] = car.sortValue(key.Field)
+	}
+	return &Cursor{Sort: sort, Values: values, Before: before}
+}
+
+func (c *Cursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor reads a cursor given by a client. Its values come back from JSON as strings and
+// float64s, so they're checked against the fields they're for and turned back into what the
+// database compares them with
+func decodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return nil, errInvalidCursor
+	}
+
+	keys := sortKeys(cursor.Sort)
+	if len(cursor.Values) != len(keys) {
+		return nil, errInvalidCursor
+	}
+	for i, key := range keys {
+		if !slices.Contains(sortableFields, key.Field) {
+			return nil, errInvalidCursor
+		}
+		if cursor.Values[i], err = cursorValue(key.Field, cursor.Values[i]); err != nil {
+			return nil, fmt.Errorf("%w: %s", errInvalidCursor, err)
+		}
+	}
+	return &cursor, nil
+}
+
+func cursorValue(field string, value any) (any, error) {
+	switch field {
+	case "company", "model":
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case "createdAt":
+		if s, ok := value.(string); ok {
+			return time.Parse(time.RFC3339Nano, s)
+		}
+	case "id", "year":
+		// never missing
+		if number, ok := value.(float64); ok {
+			return int(number), nil
+		}
+	default:
+		if value == nil {
+			return nil, nil
+		}
+		if number, ok := value.(float64); ok {
+			return number, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %s %v", field, value)
+}
+
+// CarPage is a page of cars listed from a cursor, along with the cursors of the pages around it
+type CarPage struct {
+	Data []*Car `json:"data"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
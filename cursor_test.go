@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor(t *testing.T) {
+	price := int64(25000)
+	createdAt := time.Date(2023, 6, 1, 12, 30, 0, 123456000, time.UTC)
+	car := &Car{ID: 7, Company: "Toyota", Model: "Corolla", StartYear: 2019, PriceMin: &price, CreatedAt: createdAt}
+
+	testCases := []struct {
+		name           string
+		sort           []SortField
+		before         bool
+		expectedValues []any
+	}{
+		{name: "By ID", expectedValues: []any{7}},
+		{name: "Text And Time", sort: []SortField{{Field: "model", Desc: true}, {Field: "createdAt"}}, expectedValues: []any{"Corolla", createdAt, 7}},
+		{name: "Numbers", sort: []SortField{{Field: "price"}, {Field: "year"}}, before: true, expectedValues: []any{25000.0, 2019, 7}},
+		{name: "Missing Value", sort: []SortField{{Field: "horsepower", Desc: true}}, expectedValues: []any{nil, 7}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cursor, err := decodeCursor(newCursor(car, tc.sort, tc.before).String())
+			if assert.NoError(t, err) {
+				assert.Equal(t, &Cursor{Sort: tc.sort, Values: tc.expectedValues, Before: tc.before}, cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalidCursor(t *testing.T) {
+	testCases := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "Not Base64", cursor: "not a cursor"},
+		{name: "Not JSON", cursor: "bm90IGpzb24"},
+		// {"values":[1,2]}
+		{name: "Too Many Values", cursor: "eyJ2YWx1ZXMiOlsxLDJdfQ"},
+		// {"sort":[{"field":"color"}],"values":["red",1]}
+		{name: "Unknown Field", cursor: "eyJzb3J0IjpbeyJmaWVsZCI6ImNvbG9yIn1dLCJ2YWx1ZXMiOlsicmVkIiwxXX0"},
+		// {"values":["7"]}
+		{name: "Wrong Type", cursor: "eyJ2YWx1ZXMiOlsiNyJdfQ"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := decodeCursor(tc.cursor)
+			assert.ErrorIs(t, err, errInvalidCursor)
+		})
+	}
+}
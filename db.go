@@ -3,9 +3,18 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"golang.org/x/exp/slices"
 	log "golang.org/x/exp/slog"
 )
 
@@ -13,9 +22,84 @@ import (
 // TODO create functions without ctx
 type CarDB interface {
 	CreateCar(context.Context, *Car) (int, error)
-	GetCars(context.Context, *Pagination) ([]*Car, error)
-	GetCarById(context.Context, string) (*Car, error)
-	Count() (int, error)
+	GetCars(context.Context, *Pagination, *CarFilter) ([]*Car, error)
+	GetCarById(context.Context, string, int) (*Car, error)
+	UpdateCar(context.Context, *Car) error
+	DeleteCar(context.Context, string) error
+	Count(context.Context, *CarFilter) (int, error)
+	GetManufacturers(context.Context) ([]*Manufacturer, error)
+	GetManufacturerById(context.Context, string) (*Manufacturer, error)
+	CountBodyTypes(context.Context) (map[string]int, error)
+	ImportCars(context.Context, string, []*Car, []*RejectedRow) (*ImportSummary, error)
+	GetRejectedRows(context.Context) ([]*RejectedRow, error)
+	GetRejectedRowById(context.Context, string) (*RejectedRow, error)
+	DeleteRejectedRow(context.Context, int) error
+	GetVersions(context.Context) ([]*DatasetVersion, error)
+	GetVersion(context.Context, int) (*DatasetVersion, error)
+}
+
+// carColumns lists the columns of the cars table in the order they are inserted
+// and scanned. Selecting them explicitly (rather than SELECT *) keeps scanning
+// independent of the physical column order in the table
+const carColumns = `company,
+		manufacturer_id,
+		model,
+		horsepower,
+		horsepower_min,
+		horsepower_max,
+		horsepower_unit,
+		horsepower_rpm_min,
+		horsepower_rpm_max,
+		torque,
+		torque_min_nm,
+		torque_max_nm,
+		torque_min_lb_ft,
+		torque_max_lb_ft,
+		torque_rpm_min,
+		torque_rpm_max,
+		transmission_type,
+		transmissions,
+		drivetrain,
+		drivetrains,
+		fuel_economy,
+		fuel_economy_city,
+		fuel_economy_highway,
+		fuel_economy_combined,
+		fuel_economy_source_unit,
+		number_of_doors,
+		doors,
+		price,
+		price_currency,
+		price_min,
+		price_max,
+		price_is_starting,
+		start_year,
+		end_year,
+		in_production,
+		body_type,
+		body_types,
+		engine_type,
+		engine_displacements,
+		engine_layouts,
+		engine_aspirations,
+		engine_fuels,
+		number_of_cylinders,
+		cylinders,
+		quality_warnings,
+		created_at`
+
+// carColumnNames returns carColumns as a list
+func carColumnNames() []string {
+	names := strings.Split(carColumns, ",")
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+	}
+	return names
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
 }
 
 type PostGresStore struct {
@@ -48,40 +132,285 @@ func NewPostgresStore(c *Config, creds *Credentials) (*PostGresStore, error) {
 }
 
 func (p *PostGresStore) Init() error {
-	return p.createTable()
+	// cars reference manufacturers so they have to come first
+	if err := p.createManufacturersTable(); err != nil {
+		return err
+	}
+	if err := p.createTable(); err != nil {
+		return err
+	}
+	if err := p.createNaturalKeyIndex(); err != nil {
+		return err
+	}
+	if err := p.createCarChangesTable(); err != nil {
+		return err
+	}
+	if err := p.createDatasetVersionsTable(); err != nil {
+		return err
+	}
+	if err := p.createCarRevisionsTable(); err != nil {
+		return err
+	}
+	return p.createRejectedRowsTable()
 }
 
-// TODO figure out Lakh is easily taken by the (Postgres) money data type
-// TODO determine if we should turn engine type into an array since it can have multiple (possibly comma-separated) values
-func (p *PostGresStore) createTable() error {
-	stmt := `create table if not exists cars (
+func (p *PostGresStore) createDatasetVersionsTable() error {
+	stmt := `create table if not exists dataset_versions (
 		id serial primary key,
-		company varchar(50),
-		model varchar(50), 
-		horsepower varchar(50), 
-		torque varchar(50), 
-		transmission_type varchar(50), 
-		drivetrain varchar(50), 
-		fuel_economy varchar(250), 
-		number_of_doors varchar(50), 
-		price varchar(50), 
-		start_year integer, 
-		end_year integer,
-		body_type varchar(50), 
-		engine_type varchar(100),
-		number_of_cylinders varchar(50),
-		created_at timestamp 
+		source varchar(250),
+		cars integer,
+		inserted integer,
+		updated integer,
+		unchanged integer,
+		rejected integer,
+		created_at timestamp
 	)`
 
 	_, err := p.db.Exec(stmt)
-	log.Error("An error occured while creating the cars table", "err", err)
+	if err != nil {
+		log.Error("An error occured while creating the dataset_versions table", "err", err)
+	}
+	return err
+}
+
+// createCarRevisionsTable creates the table of what each car looked like from one dataset
+// version to another. The data is the row of the car as JSON, keyed by column, and the last
+// version is null while it's still what the car looks like. Revisions outlive their car so
+// past versions keep the cars deleted since
+func (p *PostGresStore) createCarRevisionsTable() error {
+	stmt := `create table if not exists car_revisions (
+		id serial primary key,
+		car_id integer,
+		data jsonb,
+		first_version integer references dataset_versions (id),
+		last_version integer references dataset_versions (id)
+	)`
+
+	_, err := p.db.Exec(stmt)
+	if err != nil {
+		log.Error("An error occured while creating the car_revisions table", "err", err)
+	}
 	return err
 }
 
-func (p *PostGresStore) Count() (int, error) {
+// createNaturalKeyIndex makes company, model and start year unique so that imports can tell
+// which of the cars they read already exist
+func (p *PostGresStore) createNaturalKeyIndex() error {
+	stmt := "CREATE UNIQUE INDEX IF NOT EXISTS cars_natural_key ON cars (company, model, start_year)"
+	_, err := p.db.Exec(stmt)
+	if err != nil {
+		log.Error("An error occured while creating the natural key of the cars table", "err", err)
+	}
+	return err
+}
+
+func (p *PostGresStore) createCarChangesTable() error {
+	stmt := `create table if not exists car_changes (
+		id serial primary key,
+		car_id integer references cars (id) on delete cascade,
+		changes jsonb,
+		changed_at timestamp
+	)`
+
+	_, err := p.db.Exec(stmt)
+	if err != nil {
+		log.Error("An error occured while creating the car_changes table", "err", err)
+	}
+	return err
+}
+
+func (p *PostGresStore) createRejectedRowsTable() error {
+	stmt := `create table if not exists rejected_rows (
+		id serial primary key,
+		source_file varchar(250),
+		line_number integer,
+		record jsonb,
+		error text,
+		created_at timestamp
+	)`
+
+	_, err := p.db.Exec(stmt)
+	if err != nil {
+		log.Error("An error occured while creating the rejected_rows table", "err", err)
+	}
+	return err
+}
+
+func (p *PostGresStore) createManufacturersTable() error {
+	stmt := `create table if not exists manufacturers (
+		id serial primary key,
+		name varchar(50) unique not null,
+		aliases text[],
+		parent_group varchar(50),
+		country varchar(50)
+	)`
+
+	_, err := p.db.Exec(stmt)
+	if err != nil {
+		log.Error("An error occured while creating the manufacturers table", "err", err)
+	}
+	return err
+}
+
+// carColumnDefinitions defines the columns of the cars table, in the order of carColumns
+var carColumnDefinitions = []string{
+	"company varchar(50)",
+	"manufacturer_id integer references manufacturers (id)",
+	"model varchar(50)",
+	"horsepower varchar(50)",
+	"horsepower_min integer",
+	"horsepower_max integer",
+	"horsepower_unit varchar(10)",
+	"horsepower_rpm_min integer",
+	"horsepower_rpm_max integer",
+	"torque varchar(50)",
+	"torque_min_nm numeric(7, 1)",
+	"torque_max_nm numeric(7, 1)",
+	"torque_min_lb_ft numeric(7, 1)",
+	"torque_max_lb_ft numeric(7, 1)",
+	"torque_rpm_min integer",
+	"torque_rpm_max integer",
+	"transmission_type varchar(50)",
+	"transmissions jsonb",
+	"drivetrain varchar(50)",
+	"drivetrains text[]",
+	"fuel_economy varchar(250)",
+	"fuel_economy_city numeric(6, 2)",
+	"fuel_economy_highway numeric(6, 2)",
+	"fuel_economy_combined numeric(6, 2)",
+	"fuel_economy_source_unit varchar(10)",
+	"number_of_doors varchar(50)",
+	"doors integer[]",
+	"price varchar(50)",
+	"price_currency varchar(3)",
+	"price_min bigint",
+	"price_max bigint",
+	"price_is_starting boolean",
+	"start_year integer",
+	"end_year integer",
+	"in_production boolean",
+	"body_type varchar(50)",
+	"body_types text[]",
+	"engine_type varchar(100)",
+	"engine_displacements numeric(3, 1)[]",
+	"engine_layouts text[]",
+	"engine_aspirations text[]",
+	"engine_fuels text[]",
+	"number_of_cylinders varchar(50)",
+	"cylinders integer[]",
+	"quality_warnings jsonb",
+	"created_at timestamp",
+}
+
+func (p *PostGresStore) createTable() error {
+	stmt := "create table if not exists cars (id serial primary key, " + strings.Join(carColumnDefinitions, ", ") + ")"
+
+	_, err := p.db.Exec(stmt)
+	if err != nil {
+		log.Error("An error occured while creating the cars table", "err", err)
+		return err
+	}
+	return p.addCarColumns()
+}
+
+// addCarColumns adds the columns a cars table created by an earlier version is missing, since
+// create table if not exists leaves an existing table as it is
+func (p *PostGresStore) addCarColumns() error {
+	for _, definition := range carColumnDefinitions {
+		if _, err := p.db.Exec("alter table cars add column if not exists " + definition); err != nil {
+			log.Error("An error occured while adding a column to the cars table", "column", definition, "err", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// columnTypeRegex matches the name and size of the columns whose values can be too long or too
+// big for them: varchar(n), numeric(precision, scale) and integer, along with their arrays
+var columnTypeRegex = regexp.MustCompile(`^(\w+) (varchar|numeric|integer)(?:\((\d+)(?:, (\d+))?\))?(\[\])?`)
+
+// checkColumns checks that the car's values fit the columns of the cars table. COPY aborts
+// the whole import on the first row the database rejects, so the cars that wouldn't fit are
+// rejected before they get there
+func checkColumns(car *Car) error {
+	var issues Issues
+	for i, value := range carValues(car) {
+		match := columnTypeRegex.FindStringSubmatch(carColumnDefinitions[i])
+		if match == nil {
+			continue
+		}
+		column, kind := match[1], match[2]
+		size, _ := strconv.Atoi(match[3])
+		scale, _ := strconv.Atoi(match[4])
+
+		switch kind {
+		case "varchar":
+			if s, ok := value.(string); ok && utf8.RuneCountInString(s) > size {
+				issues = append(issues, Issue{Column: column, Message: fmt.Sprintf("longer than %d characters", size)})
+			}
+		case "numeric":
+			// the digits left of the decimal point, once rounded to the scale
+			limit := math.Pow10(size - scale)
+			for _, n := range columnFloats(value) {
+				if math.Abs(math.Round(n*math.Pow10(scale))/math.Pow10(scale)) >= limit {
+					issues = append(issues, Issue{Column: column, Message: fmt.Sprintf("%s is too big, it must be less than %.0f", strconv.FormatFloat(n, 'f', -1, 64), limit)})
+					break
+				}
+			}
+		case "integer":
+			for _, n := range columnInts(value) {
+				if n > math.MaxInt32 || n < math.MinInt32 {
+					issues = append(issues, Issue{Column: column, Message: fmt.Sprintf("%d is too big for an integer", n)})
+					break
+				}
+			}
+		}
+	}
+	if len(issues) > 0 {
+		return issues
+	}
+	return nil
+}
+
+// columnFloats returns the numbers of a numeric value as given by carValues
+func columnFloats(value any) []float64 {
+	switch v := value.(type) {
+	case *float64:
+		if v != nil {
+			return []float64{*v}
+		}
+	case *pq.Float64Array:
+		return *v
+	}
+	return nil
+}
+
+// columnInts returns the numbers of an integer value as given by carValues
+func columnInts(value any) []int64 {
+	switch v := value.(type) {
+	case int:
+		return []int64{int64(v)}
+	case *int:
+		if v != nil {
+			return []int64{int64(*v)}
+		}
+	case *pq.Int64Array:
+		return *v
+	}
+	return nil
+}
+
+// Count returns the number of cars matching the filter
+func (p *PostGresStore) Count(ctx context.Context, filter *CarFilter) (int, error) {
 	var count int
-	countStmt := "SELECT COUNT(company) from cars"
-	row := p.db.QueryRow(countStmt)
+	where, args := filter.where()
+	var version int
+	if filter != nil {
+		version = filter.Version
+	}
+	from, args := carsAt(version, args)
+	countStmt := "SELECT COUNT(*) FROM " + from + where
+	row := p.db.QueryRowContext(ctx, countStmt, args...)
 	switch err := row.Scan(&count); err {
 	case sql.ErrNoRows:
 		return 0, nil
@@ -118,53 +447,37 @@ func (p *PostGresStore) TableExists() (bool, error) {
 	}
 }
 
-func (p *PostGresStore) CreateCar(ctx context.Context, car *Car) (int, error) {
+func (p *PostGresStore) CreateCar(ctx context.Context, car *Car) (id int, err error) {
 	log.Debug("Inserting a car into DB", "car", car.String())
-	var id int
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
 
+	values := carValues(car)
 	insertStmt := `
-	INSERT INTO cars (
-		company, 
-		model, 
-		horsepower,
-		torque, 
-		transmission_type, 
-		drivetrain, 
-		fuel_economy, 
-		number_of_doors, 
-		price, 
-		start_year, 
-		end_year, 
-		body_type, 
-		engine_type, 
-		number_of_cylinders, 
-		created_at
-	)	
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	INSERT INTO cars (` + carColumns + `)	
+	VALUES (` + placeholders(1, len(values)) + `)
 	RETURNING id`
 
-	err := p.db.QueryRowContext(
-		ctx,
-		insertStmt,
-		&car.Company,
-		&car.Model,
-		&car.Horsepower,
-		&car.Torque,
-		&car.TransmissionType,
-		&car.Drivetrain,
-		&car.FuelEconomy,
-		&car.NumberOfDoors,
-		&car.Price,
-		&car.StartYear,
-		&car.EndYear,
-		&car.BodyType,
-		&car.EngineType,
-		&car.NumberofCylinders,
-		&car.CreatedAt,
-	).Scan(&id)
+	err = tx.QueryRowContext(ctx, insertStmt, values...).Scan(&id)
 
 	if err != nil {
 		log.Error("An error occurred while inserting to db", "err", err)
+		if isUniqueViolation(err) {
+			return 0, errDuplicateCar
+		}
+		return 0, err
+	}
+	if _, err = createVersion(ctx, tx, apiVersionSource, &ImportSummary{Inserted: 1}, id); err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
 		return 0, err
 	}
 
@@ -172,56 +485,128 @@ func (p *PostGresStore) CreateCar(ctx context.Context, car *Car) (int, error) {
 	return id, nil
 }
 
-func (p *PostGresStore) GetCarById(ctx context.Context, id string) (*Car, error) {
+// uniqueViolation is the Postgres error code of an insert breaking a unique index
+const uniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
+// GetCarById returns the car as it is now, or as it was at the given dataset version
+func (p *PostGresStore) GetCarById(ctx context.Context, id string, version int) (*Car, error) {
 	var car Car
 
 	// Query for a value based on a single row.
-	err := p.db.QueryRowContext(ctx, "SELECT * FROM cars WHERE id = $1", id).Scan(
-		&car.ID,
-		&car.Company,
-		&car.Model,
-		&car.Horsepower,
-		&car.Torque,
-		&car.TransmissionType,
-		&car.Drivetrain,
-		&car.FuelEconomy,
-		&car.NumberOfDoors,
-		&car.Price,
-		&car.StartYear,
-		&car.EndYear,
-		&car.BodyType,
-		&car.EngineType,
-		&car.NumberofCylinders,
-		&car.CreatedAt,
-	)
+	from, args := carsAt(version, []any{id})
+	row := p.db.QueryRowContext(ctx, "SELECT id, "+carColumns+" FROM "+from+" WHERE id = $1", args...)
+	err := scanCar(row, &car)
 	if err != nil {
-		// TODO use custom error
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("car not found: %s", id)
+			return nil, fmt.Errorf("%w: %s", errCarNotFound, id)
 		}
 		return nil, err
 	}
 	return &car, nil
 }
 
+// UpdateCar replaces the stored car having the car's ID, keeping its creation time. The
+// fields that changed are recorded in car_changes, the same as for re-imports
+func (p *PostGresStore) UpdateCar(ctx context.Context, car *Car) (err error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var existing Car
+	row := tx.QueryRowContext(ctx, "SELECT id, "+carColumns+" FROM cars WHERE id = $1 FOR UPDATE", car.ID)
+	if err = scanCar(row, &existing); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %d", errCarNotFound, car.ID)
+		}
+		return err
+	}
+	car.CreatedAt = existing.CreatedAt
+
+	changes, err := existing.Diff(car)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return tx.Commit()
+	}
+
+	if err = updateCar(ctx, tx, car); err != nil {
+		log.Error("An error occurred while updating a car", "id", car.ID, "err", err)
+		if isUniqueViolation(err) {
+			return errDuplicateCar
+		}
+		return err
+	}
+	if err = insertCarChanges(ctx, tx, car.ID, changes); err != nil {
+		return err
+	}
+	if _, err = createVersion(ctx, tx, apiVersionSource, &ImportSummary{Updated: 1}, car.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *PostGresStore) DeleteCar(ctx context.Context, id string) (err error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var deleted int
+	if err = tx.QueryRowContext(ctx, "DELETE FROM cars WHERE id = $1 RETURNING id", id).Scan(&deleted); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %s", errCarNotFound, id)
+		}
+		log.Error("An error occurred while deleting a car", "id", id, "err", err)
+		return err
+	}
+	if _, err = createVersion(ctx, tx, apiVersionSource, &ImportSummary{}, deleted); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 // TODO implement pagination
-func (p *PostGresStore) GetCars(ctx context.Context, page *Pagination) ([]*Car, error) {
+func (p *PostGresStore) GetCars(ctx context.Context, page *Pagination, filter *CarFilter) ([]*Car, error) {
+	where, args := filter.where()
+	var version int
+	if filter != nil {
+		version = filter.Version
+	}
+	from, args := carsAt(version, args)
+
 	// pretty sure it's unlikely that page will be nil; however,
 	// in case it is I've decided to separate the paginated query
 	// to its own method. Not making that a part of the public API
 	// since this should be transparent to the user
 	if page != nil {
-		return p.getCarsWithPagination(ctx, page)
+		return p.getCarsWithPagination(ctx, page, from, where, args)
 	}
 	
-	selectAllStmt := "SELECT * FROM cars"
+	selectAllStmt := "SELECT id, " + carColumns + " FROM " + from + where + orderBy(nil, false)
 	stmt, err := p.db.PrepareContext(ctx, selectAllStmt)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.QueryContext(ctx)
+	rows, err := stmt.QueryContext(ctx, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -229,8 +614,12 @@ func (p *PostGresStore) GetCars(ctx context.Context, page *Pagination) ([]*Car,
 	return p.getCars(rows)
 }
 
-func (p *PostGresStore) getCarsWithPagination(ctx context.Context, page *Pagination) ([]*Car, error) {
-	selectAllStmt := "SELECT * FROM cars LIMIT $1 OFFSET $2"
+func (p *PostGresStore) getCarsWithPagination(ctx context.Context, page *Pagination, from, where string, args []any) ([]*Car, error) {
+	if page.Cursor != nil {
+		return p.getCarsFromCursor(ctx, page, from, where, args)
+	}
+
+	selectAllStmt := fmt.Sprintf("SELECT id, %s FROM %s%s%s LIMIT $%d OFFSET $%d", carColumns, from, where, orderBy(page.Sort, false), len(args)+1, len(args)+2)
 
 	stmt, err := p.db.PrepareContext(ctx, selectAllStmt)
 	if err != nil {
@@ -238,7 +627,7 @@ func (p *PostGresStore) getCarsWithPagination(ctx context.Context, page *Paginat
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.QueryContext(ctx, page.Limit, page.Offset)
+	rows, err := stmt.QueryContext(ctx, append(args, page.Limit, page.Offset)...)
 	if err != nil {
 		return nil, err
 	}
@@ -246,30 +635,626 @@ func (p *PostGresStore) getCarsWithPagination(ctx context.Context, page *Paginat
 	return p.getCars(rows)
 }
 
+// getCarsFromCursor lists the cars right after the cursor, or right before it, using the sort
+// keys rather than an offset so that the pages don't shift as cars come and go. Cars before
+// the cursor are queried in reverse and put back in order
+func (p *PostGresStore) getCarsFromCursor(ctx context.Context, page *Pagination, from, where string, args []any) ([]*Car, error) {
+	condition, args := keyset(page.Cursor, args)
+	if where == "" {
+		where = " WHERE " + condition
+	} else {
+		where += " AND " + condition
+	}
+	selectStmt := fmt.Sprintf("SELECT id, %s FROM %s%s%s LIMIT $%d", carColumns, from, where, orderBy(page.Sort, page.Cursor.Before), len(args)+1)
+
+	rows, err := p.db.QueryContext(ctx, selectStmt, append(args, page.Limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cars, err := p.getCars(rows)
+	if err != nil {
+		return nil, err
+	}
+	if page.Cursor.Before {
+		for i, j := 0, len(cars)-1; i < j; i, j = i+1, j-1 {
+			cars[i], cars[j] = cars[j], cars[i]
+		}
+	}
+	return cars, nil
+}
+
+// ImportCars stores the cars read from the source along with the rows rejected while reading
+// them in a single transaction so that an import is all or nothing. Cars that already exist
+// (by company, model and start year) are updated when they've changed, with the changes
+// recorded in car_changes, and left alone otherwise. New cars are loaded with COPY rather
+// than an INSERT apiece. The source's rejections replace the ones of its previous import
+func (p *PostGresStore) ImportCars(ctx context.Context, source string, cars []*Car, rejected []*RejectedRow) (summary *ImportSummary, err error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			log.Error("An error occurred while importing cars, rolling back", "err", err)
+			tx.Rollback()
+		}
+	}()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, "+carColumns+" FROM cars")
+	if err != nil {
+		return nil, err
+	}
+	stored, err := p.getCars(rows)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[naturalKey]*Car, len(stored))
+	for _, car := range stored {
+		existing[car.naturalKey()] = car
+	}
+
+	summary = &ImportSummary{Rejected: len(rejected)}
+	var inserts []*Car
+	for _, car := range cars {
+		old, ok := existing[car.naturalKey()]
+		if !ok {
+			inserts = append(inserts, car)
+			continue
+		}
+
+		car.ID = old.ID
+		var changes map[string]Change
+		if changes, err = old.Diff(car); err != nil {
+			return nil, err
+		}
+		if len(changes) == 0 {
+			summary.Unchanged++
+			continue
+		}
+
+		car.CreatedAt = old.CreatedAt
+		if err = updateCar(ctx, tx, car); err != nil {
+			return nil, err
+		}
+		if err = insertCarChanges(ctx, tx, car.ID, changes); err != nil {
+			return nil, err
+		}
+		summary.addUpdate(car, changes)
+	}
+
+	if err = copyCars(ctx, tx, inserts); err != nil {
+		return nil, err
+	}
+	summary.Inserted = len(inserts)
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM rejected_rows WHERE source_file = $1", source); err != nil {
+		return nil, err
+	}
+	for _, row := range rejected {
+		if row.ID, err = insertRejectedRow(ctx, tx, row); err != nil {
+			return nil, err
+		}
+	}
+
+	if summary.Version, err = createVersion(ctx, tx, source, summary, 0); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	log.Debug("Succesfully imported cars", "source", source, "summary", summary)
+	return summary, nil
+}
+
+// apiVersionSource is the source of the dataset versions created by cars being written
+// through the API rather than imported
+const apiVersionSource = "api"
+
+// versionLock is the key of the advisory lock taken while a dataset version is created, so that
+// versions are created one at a time whichever instance of the API is writing
+const versionLock = 8371
+
+// createVersion records the state the cars are in after an import, or a car written through
+// the API, as a new dataset version. Cars created, changed or deleted since the previous
+// version get their revisions opened or closed. Only the revisions of the car with the given
+// ID are looked at when it's not 0, as nothing else changed when a single car was written
+func createVersion(ctx context.Context, tx *sql.Tx, source string, summary *ImportSummary, carID int) (int, error) {
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", versionLock); err != nil {
+		return 0, err
+	}
+
+	var version int
+	insertStmt := `
+	INSERT INTO dataset_versions (source, cars, inserted, updated, unchanged, rejected, created_at)
+	VALUES ($1, (SELECT COUNT(*) FROM cars), $2, $3, $4, $5, $6)
+	RETURNING id`
+	err := tx.QueryRowContext(ctx, insertStmt, source, summary.Inserted, summary.Updated, summary.Unchanged, summary.Rejected, time.Now().UTC()).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	args := []any{version}
+	revisionScope, carScope := "", ""
+	if carID != 0 {
+		args = append(args, carID)
+		revisionScope, carScope = " AND r.car_id = $2", " AND c.id = $2"
+	}
+
+	// the revisions of cars that changed or are gone end with the previous version. Versions
+	// whose transaction was rolled back leave gaps in the IDs, so it's looked up
+	closeStmt := `
+	UPDATE car_revisions r SET last_version = (SELECT max(id) FROM dataset_versions WHERE id < $1)
+	WHERE r.last_version IS NULL` + revisionScope + `
+	AND NOT EXISTS (SELECT 1 FROM cars c WHERE c.id = r.car_id AND to_jsonb(c) = r.data)`
+	if _, err := tx.ExecContext(ctx, closeStmt, args...); err != nil {
+		return 0, err
+	}
+
+	// which leaves the cars that are new or changed without a revision
+	openStmt := `
+	INSERT INTO car_revisions (car_id, data, first_version)
+	SELECT c.id, to_jsonb(c), $1 FROM cars c
+	WHERE NOT EXISTS (SELECT 1 FROM car_revisions r WHERE r.car_id = c.id AND r.last_version IS NULL)` + carScope
+	if _, err := tx.ExecContext(ctx, openStmt, args...); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// carsAt returns what to select cars from: the cars table, or the cars as they were at the
+// given dataset version, rebuilt from their revisions with the same columns. The version is
+// added to the args of the query when it's needed
+func carsAt(version int, args []any) (string, []any) {
+	if version <= 0 {
+		return "cars", args
+	}
+	args = append(args, version)
+	from := fmt.Sprintf(`(
+		SELECT (jsonb_populate_record(null::cars, data)).* FROM car_revisions
+		WHERE first_version <= $%d AND (last_version IS NULL OR last_version >= $%d)
+	) AS cars`, len(args), len(args))
+	return from, args
+}
+
+const datasetVersionColumns = "id, source, cars, inserted, updated, unchanged, rejected, created_at"
+
+// scanDatasetVersion scans a row selected as datasetVersionColumns into version
+func scanDatasetVersion(scanner rowScanner, version *DatasetVersion) error {
+	return scanner.Scan(&version.ID, &version.Source, &version.Cars, &version.Inserted, &version.Updated, &version.Unchanged, &version.Rejected, &version.CreatedAt)
+}
+
+func (p *PostGresStore) GetVersions(ctx context.Context) ([]*DatasetVersion, error) {
+	rows, err := p.db.QueryContext(ctx, "SELECT "+datasetVersionColumns+" FROM dataset_versions ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []*DatasetVersion{}
+	for rows.Next() {
+		version := new(DatasetVersion)
+		if err := scanDatasetVersion(rows, version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (p *PostGresStore) GetVersion(ctx context.Context, id int) (*DatasetVersion, error) {
+	var version DatasetVersion
+
+	err := scanDatasetVersion(p.db.QueryRowContext(ctx, "SELECT "+datasetVersionColumns+" FROM dataset_versions WHERE id = $1", id), &version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("dataset version not found: %d", id)
+		}
+		return nil, err
+	}
+	return &version, nil
+}
+
+// copyCars loads the cars with COPY
+func copyCars(ctx context.Context, tx *sql.Tx, cars []*Car) error {
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("cars", carColumnNames()...))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, car := range cars {
+		if _, err := stmt.ExecContext(ctx, carValues(car)...); err != nil {
+			return err
+		}
+	}
+	// an Exec without values flushes the buffered rows
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return err
+	}
+	return stmt.Close()
+}
+
+func updateCar(ctx context.Context, tx *sql.Tx, car *Car) error {
+	values := carValues(car)
+	updateStmt := fmt.Sprintf("UPDATE cars SET (%s) = (%s) WHERE id = $%d", carColumns, placeholders(1, len(values)), len(values)+1)
+	_, err := tx.ExecContext(ctx, updateStmt, append(values, car.ID)...)
+	return err
+}
+
+func insertCarChanges(ctx context.Context, tx *sql.Tx, carID int, changes map[string]Change) error {
+	b, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	insertStmt := "INSERT INTO car_changes (car_id, changes, changed_at) VALUES ($1, $2, $3)"
+	_, err = tx.ExecContext(ctx, insertStmt, carID, string(b), time.Now().UTC())
+	return err
+}
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func insertRejectedRow(ctx context.Context, q queryRower, row *RejectedRow) (int, error) {
+	var id int
+
+	insertStmt := `
+	INSERT INTO rejected_rows (source_file, line_number, record, error, created_at)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id`
+
+	// the record is given as a string so that it isn't taken as bytea
+	err := q.QueryRowContext(ctx, insertStmt, row.SourceFile, row.LineNumber, string(row.Record), row.Error, row.CreatedAt).Scan(&id)
+	if err != nil {
+		log.Error("An error occurred while inserting a rejected row", "err", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+func (p *PostGresStore) GetRejectedRows(ctx context.Context) ([]*RejectedRow, error) {
+	rows, err := p.db.QueryContext(ctx, "SELECT "+rejectedRowColumns+" FROM rejected_rows ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rejectedRows := []*RejectedRow{}
+	for rows.Next() {
+		row := new(RejectedRow)
+		if err := scanRejectedRow(rows, row); err != nil {
+			return nil, err
+		}
+		rejectedRows = append(rejectedRows, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return rejectedRows, nil
+}
+
+func (p *PostGresStore) GetRejectedRowById(ctx context.Context, id string) (*RejectedRow, error) {
+	var row RejectedRow
+
+	err := scanRejectedRow(p.db.QueryRowContext(ctx, "SELECT "+rejectedRowColumns+" FROM rejected_rows WHERE id = $1", id), &row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("rejected row not found: %s", id)
+		}
+		return nil, err
+	}
+	return &row, nil
+}
+
+func (p *PostGresStore) DeleteRejectedRow(ctx context.Context, id int) error {
+	_, err := p.db.ExecContext(ctx, "DELETE FROM rejected_rows WHERE id = $1", id)
+	return err
+}
+
+const rejectedRowColumns = "id, source_file, line_number, record, error, created_at"
+
+// scanRejectedRow scans a row selected as rejectedRowColumns into row
+func scanRejectedRow(scanner rowScanner, row *RejectedRow) error {
+	var record []byte
+	err := scanner.Scan(&row.ID, &row.SourceFile, &row.LineNumber, &record, &row.Error, &row.CreatedAt)
+	row.Record = record
+	return err
+}
+
+// CountBodyTypes returns the number of cars having each body type. Body types without
+// any cars are left out
+func (p *PostGresStore) CountBodyTypes(ctx context.Context) (map[string]int, error) {
+	countStmt := "SELECT body_type, COUNT(*) FROM cars, unnest(body_types) AS body_type GROUP BY body_type"
+	rows, err := p.db.QueryContext(ctx, countStmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var bodyType string
+		var count int
+		if err := rows.Scan(&bodyType, &count); err != nil {
+			return nil, err
+		}
+		counts[bodyType] = count
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// SeedManufacturers inserts the given manufacturers, updating the ones that already exist
+// (by name) so that the table follows the seed file
+func (p *PostGresStore) SeedManufacturers(ctx context.Context, manufacturers []*Manufacturer) error {
+	upsertStmt := `
+	INSERT INTO manufacturers (name, aliases, parent_group, country)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (name) DO UPDATE
+	SET aliases = EXCLUDED.aliases, parent_group = EXCLUDED.parent_group, country = EXCLUDED.country
+	RETURNING id`
+
+	stmt, err := p.db.PrepareContext(ctx, upsertStmt)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, m := range manufacturers {
+		err := stmt.QueryRowContext(ctx, m.Name, pq.Array(m.Aliases), m.ParentGroup, m.Country).Scan(&m.ID)
+		if err != nil {
+			log.Error("Could not seed manufacturer", "manufacturer", m.Name, "err", err)
+			return err
+		}
+	}
+	log.Debug("Seeded manufacturers", "count", len(manufacturers))
+	return nil
+}
+
+func (p *PostGresStore) GetManufacturers(ctx context.Context) ([]*Manufacturer, error) {
+	rows, err := p.db.QueryContext(ctx, "SELECT "+manufacturerColumns+" FROM manufacturers ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	manufacturers := []*Manufacturer{}
+	for rows.Next() {
+		manufacturer := new(Manufacturer)
+		if err := scanManufacturer(rows, manufacturer); err != nil {
+			return nil, err
+		}
+		manufacturers = append(manufacturers, manufacturer)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return manufacturers, nil
+}
+
+func (p *PostGresStore) GetManufacturerById(ctx context.Context, id string) (*Manufacturer, error) {
+	var manufacturer Manufacturer
+
+	row := p.db.QueryRowContext(ctx, "SELECT "+manufacturerColumns+" FROM manufacturers WHERE id = $1", id)
+	err := scanManufacturer(row, &manufacturer)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("manufacturer not found: %s", id)
+		}
+		return nil, err
+	}
+	return &manufacturer, nil
+}
+
+const manufacturerColumns = "id, name, aliases, parent_group, country"
+
+// scanManufacturer scans a row selected as manufacturerColumns into manufacturer
+func scanManufacturer(row rowScanner, manufacturer *Manufacturer) error {
+	var parentGroup, country sql.NullString
+	err := row.Scan(&manufacturer.ID, &manufacturer.Name, pq.Array(&manufacturer.Aliases), &parentGroup, &country)
+	manufacturer.ParentGroup = parentGroup.String
+	manufacturer.Country = country.String
+	return err
+}
+
+// sortColumns gives the column each of the sortableFields sorts by
+var sortColumns = map[string]string{
+	"id":         "id",
+	"company":    "company",
+	"model":      "model",
+	"year":       "start_year",
+	"price":      "price_min",
+	"horsepower": "horsepower_min",
+	"torque":     "torque_min_nm",
+	"createdAt":  "created_at",
+}
+
+// orderBy builds the ORDER BY clause (with a leading space) sorting by the fields. Cars
+// missing a value come last either way, and ties are broken by ID so that the order is the
+// same from one query to the next, which pages rely on. Reversed, the order is the exact
+// opposite, to go back from a cursor
+func orderBy(fields []SortField, reversed bool) string {
+	var terms []string
+	for _, key := range sortKeys(fields) {
+		direction, nulls := "ASC", "NULLS LAST"
+		if key.Desc != reversed {
+			direction = "DESC"
+		}
+		if reversed {
+			nulls = "NULLS FIRST"
+		}
+		terms = append(terms, sortColumns[key.Field]+" "+direction+" "+nulls)
+	}
+	return " ORDER BY " + strings.Join(terms, ", ")
+}
+
+// keyset builds the condition matching the cars listed after the cursor (or before it), in
+// the order of orderBy, adding the cursor's values to the args. It's the expanded form of a
+// row comparison since each key may go its own direction and be null:
+//
+//	k1 > v1 OR (k1 = v1 AND k2 > v2) OR ...
+func keyset(cursor *Cursor, args []any) (string, []any) {
+	keys := sortKeys(cursor.Sort)
+	placeholders := make([]string, len(keys))
+	for i, value := range cursor.Values {
+		if value != nil {
+			args = append(args, value)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+	}
+
+	var alternatives []string
+	for i, key := range keys {
+		var terms []string
+		for j := 0; j < i; j++ {
+			column := sortColumns[keys[j].Field]
+			if placeholders[j] == "" {
+				terms = append(terms, column+" IS NULL")
+			} else {
+				terms = append(terms, column+" = "+placeholders[j])
+			}
+		}
+
+		// nulls come last, so nothing is after them and everything else is before them
+		column := sortColumns[key.Field]
+		switch {
+		case placeholders[i] == "" && cursor.Before:
+			terms = append(terms, column+" IS NOT NULL")
+		case placeholders[i] == "":
+			continue
+		case cursor.Before || key.Field == "id":
+			// IDs are never null
+			terms = append(terms, fmt.Sprintf("%s %s %s", column, comparison(key.Desc != cursor.Before), placeholders[i]))
+		default:
+			terms = append(terms, fmt.Sprintf("(%s %s %s OR %s IS NULL)", column, comparison(key.Desc), placeholders[i], column))
+		}
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+
+	if len(alternatives) == 0 {
+		return "FALSE", args
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+// comparison returns the operator matching values after the ones compared to, in ascending
+// order or not
+func comparison(descending bool) string {
+	if descending {
+		return "<"
+	}
+	return ">"
+}
+
+// rangeColumns gives the lowest and highest value of each field range filters compare. Cars
+// still in production have no end year, so their years go on
+var rangeColumns = map[string][2]string{
+	"price":      {"price_min", "price_max"},
+	"horsepower": {"horsepower_min", "horsepower_max"},
+	"torque":     {"torque_min_nm", "torque_max_nm"},
+	"year":       {"start_year", "CASE WHEN in_production THEN 'Infinity'::float8 ELSE end_year END"},
+}
+
+// integerRangeFields are the fields range filters compare with integer columns, so they can
+// only be compared to whole numbers
+var integerRangeFields = []string{"horsepower", "year"}
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// where builds the WHERE clause (with a leading space) for the filter along with its
+// arguments, numbered from $1. An empty clause is returned when nothing is filtered
+func (f *CarFilter) where() (string, []any) {
+	if f == nil {
+		return "", nil
+	}
+
+	var conditions []string
+	var args []any
+	if f.ManufacturerID != 0 {
+		args = append(args, f.ManufacturerID)
+		conditions = append(conditions, fmt.Sprintf("manufacturer_id = $%d", len(args)))
+	}
+	if f.Year != 0 {
+		// the same as YearRange.Contains
+		args = append(args, f.Year)
+		conditions = append(conditions, fmt.Sprintf("start_year <= $%d AND (in_production OR end_year >= $%d)", len(args), len(args)))
+	}
+	if f.Drivetrain != "" {
+		args = append(args, f.Drivetrain)
+		conditions = append(conditions, fmt.Sprintf("$%d = ANY(drivetrains)", len(args)))
+	}
+	if f.Transmission != "" {
+		args = append(args, Transmissions{{Kind: f.Transmission}}.containment())
+		conditions = append(conditions, fmt.Sprintf("transmissions @> $%d::jsonb", len(args)))
+	}
+	if f.Company != "" {
+		args = append(args, f.Company)
+		conditions = append(conditions, fmt.Sprintf("lower(company) = lower($%d)", len(args)))
+	}
+	if f.Model != "" {
+		args = append(args, "%"+likeEscaper.Replace(f.Model)+"%")
+		conditions = append(conditions, fmt.Sprintf("model ILIKE $%d", len(args)))
+	}
+	if f.BodyType != "" {
+		args = append(args, f.BodyType)
+		conditions = append(conditions, fmt.Sprintf("$%d = ANY(body_types)", len(args)))
+	}
+	if f.Currency != "" {
+		args = append(args, f.Currency)
+		conditions = append(conditions, fmt.Sprintf("price_currency = $%d", len(args)))
+	}
+	for _, r := range f.Ranges {
+		columns, ok := rangeColumns[r.Field]
+		if !ok {
+			continue
+		}
+		var value any = r.Value
+		switch {
+		case r.Field == "price":
+			// prices are stored in minor units
+			value = int64(math.Round(r.Value * 100))
+		case slices.Contains(integerRangeFields, r.Field):
+			value = int64(r.Value)
+		}
+		args = append(args, value)
+		n := len(args)
+		switch r.Op {
+		case rangeEq:
+			conditions = append(conditions, fmt.Sprintf("%s <= $%d AND %s >= $%d", columns[0], n, columns[1], n))
+		case rangeLt:
+			conditions = append(conditions, fmt.Sprintf("%s < $%d", columns[0], n))
+		case rangeLte:
+			conditions = append(conditions, fmt.Sprintf("%s <= $%d", columns[0], n))
+		case rangeGt:
+			conditions = append(conditions, fmt.Sprintf("%s > $%d", columns[1], n))
+		case rangeGte:
+			conditions = append(conditions, fmt.Sprintf("%s >= $%d", columns[1], n))
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
 func (*PostGresStore) getCars(rows *sql.Rows) ([]*Car, error) {
 	var err error
 	
 	cars := []*Car{}
 	for rows.Next() {
 		car := new(Car)
-		err := rows.Scan(
-			&car.ID,
-			&car.Company,
-			&car.Model,
-			&car.Horsepower,
-			&car.Torque,
-			&car.TransmissionType,
-			&car.Drivetrain,
-			&car.FuelEconomy,
-			&car.NumberOfDoors,
-			&car.Price,
-			&car.StartYear,
-			&car.EndYear,
-			&car.BodyType,
-			&car.EngineType,
-			&car.NumberofCylinders,
-			&car.CreatedAt,
-		)
+		err := scanCar(rows, car)
 
 		if err != nil {
 			return nil, err
@@ -282,3 +1267,143 @@ func (*PostGresStore) getCars(rows *sql.Rows) ([]*Car, error) {
 	}
 	return cars, nil
 }
+
+
+// carValues returns the car's fields in the same order as carColumns
+func carValues(car *Car) []any {
+	return []any{
+		car.Company,
+		car.ManufacturerID,
+		car.Model,
+		car.Horsepower,
+		car.HorsepowerMin,
+		car.HorsepowerMax,
+		car.HorsepowerUnit,
+		car.HorsepowerRPMMin,
+		car.HorsepowerRPMMax,
+		car.Torque,
+		car.TorqueMinNm,
+		car.TorqueMaxNm,
+		car.TorqueMinLbFt,
+		car.TorqueMaxLbFt,
+		car.TorqueRPMMin,
+		car.TorqueRPMMax,
+		car.TransmissionType,
+		car.Transmissions,
+		car.Drivetrain,
+		pq.Array(car.Drivetrains),
+		car.FuelEconomy,
+		car.FuelEconomyCity,
+		car.FuelEconomyHighway,
+		car.FuelEconomyCombined,
+		car.FuelEconomySourceUnit,
+		car.NumberOfDoors,
+		pq.Array(car.Doors),
+		car.Price,
+		car.PriceCurrency,
+		car.PriceMin,
+		car.PriceMax,
+		car.PriceIsStarting,
+		car.StartYear,
+		car.EndYear,
+		car.InProduction,
+		car.BodyType,
+		pq.Array(car.BodyTypes),
+		car.EngineType,
+		pq.Array(car.EngineDisplacements),
+		pq.Array(car.EngineLayouts),
+		pq.Array(car.EngineAspirations),
+		pq.Array(car.EngineFuels),
+		car.NumberofCylinders,
+		pq.Array(car.Cylinders),
+		car.Warnings,
+		car.CreatedAt,
+	}
+}
+
+// placeholders returns count comma-separated positional parameters starting at $start
+func placeholders(start, count int) string {
+	params := make([]string, count)
+	for i := range params {
+		params[i] = "$" + strconv.Itoa(start+i)
+	}
+	return strings.Join(params, ", ")
+}
+
+// scanCar scans a row selected as "id, " + carColumns into car
+func scanCar(row rowScanner, car *Car) error {
+	// columns added to a table created by an earlier version are null for the cars already in it
+	var horsepowerUnit, fuelEconomySourceUnit, priceCurrency sql.NullString
+	var priceIsStarting, inProduction sql.NullBool
+	err := row.Scan(
+		&car.ID,
+		&car.Company,
+		&car.ManufacturerID,
+		&car.Model,
+		&car.Horsepower,
+		&car.HorsepowerMin,
+		&car.HorsepowerMax,
+		&horsepowerUnit,
+		&car.HorsepowerRPMMin,
+		&car.HorsepowerRPMMax,
+		&car.Torque,
+		&car.TorqueMinNm,
+		&car.TorqueMaxNm,
+		&car.TorqueMinLbFt,
+		&car.TorqueMaxLbFt,
+		&car.TorqueRPMMin,
+		&car.TorqueRPMMax,
+		&car.TransmissionType,
+		&car.Transmissions,
+		&car.Drivetrain,
+		pq.Array(&car.Drivetrains),
+		&car.FuelEconomy,
+		&car.FuelEconomyCity,
+		&car.FuelEconomyHighway,
+		&car.FuelEconomyCombined,
+		&fuelEconomySourceUnit,
+		&car.NumberOfDoors,
+		pq.Array(&car.Doors),
+		&car.Price,
+		&priceCurrency,
+		&car.PriceMin,
+		&car.PriceMax,
+		&priceIsStarting,
+		&car.StartYear,
+		&car.EndYear,
+		&inProduction,
+		&car.BodyType,
+		pq.Array(&car.BodyTypes),
+		&car.EngineType,
+		pq.Array(&car.EngineDisplacements),
+		pq.Array(&car.EngineLayouts),
+		pq.Array(&car.EngineAspirations),
+		pq.Array(&car.EngineFuels),
+		&car.NumberofCylinders,
+		pq.Array(&car.Cylinders),
+		&car.Warnings,
+		&car.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	car.HorsepowerUnit, car.FuelEconomySourceUnit, car.PriceCurrency = horsepowerUnit.String, fuelEconomySourceUnit.String, priceCurrency.String
+	car.PriceIsStarting, car.InProduction = priceIsStarting.Bool, inProduction.Bool
+
+	// economy is always stored in L/100km
+	if car.FuelEconomySourceUnit != "" {
+		car.FuelEconomyUnit = fuelEconomyUnitL100km
+	}
+	return nil
+}
+
+// containment returns the JSON used to match the transmissions' kinds with the
+// jsonb containment operator, leaving out the gears and labels
+func (t Transmissions) containment() string {
+	kinds := make([]map[string]string, len(t))
+	for i, transmission := range t {
+		kinds[i] = map[string]string{"kind": transmission.Kind}
+	}
+	b, _ := json.Marshal(kinds)
+	return string(b)
+}
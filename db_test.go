@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCarFilterWhere(t *testing.T) {
+	testCases := []struct {
+		name          string
+		filter        *CarFilter
+		expectedWhere string
+		expectedArgs  []any
+	}{
+		{name: "No Filter", filter: nil},
+		{name: "Empty Filter", filter: &CarFilter{}},
+		{
+			name:          "Company And Model",
+			filter:        &CarFilter{Company: "Toyota", Model: "100%_"},
+			expectedWhere: ` WHERE lower(company) = lower($1) AND model ILIKE $2`,
+			expectedArgs:  []any{"Toyota", `%100\%\_%`},
+		},
+		{
+			name:          "Body Type And Drivetrain",
+			filter:        &CarFilter{Drivetrain: DrivetrainAWD, BodyType: "suv"},
+			expectedWhere: ` WHERE $1 = ANY(drivetrains) AND $2 = ANY(body_types)`,
+			expectedArgs:  []any{DrivetrainAWD, "suv"},
+		},
+		{
+			name: "Ranges",
+			filter: &CarFilter{Currency: "USD", Ranges: []RangeFilter{
+				{Field: "price", Op: rangeLte, Value: 50000},
+				{Field: "horsepower", Op: rangeGt, Value: 300},
+				{Field: "torque", Op: rangeEq, Value: 400},
+			}},
+			expectedWhere: ` WHERE price_currency = $1 AND price_min <= $2 AND horsepower_max > $3 AND torque_min_nm <= $4 AND torque_max_nm >= $4`,
+			expectedArgs:  []any{"USD", int64(5000000), int64(300), 400.0},
+		},
+		{
+			name:          "Fractional Price",
+			filter:        &CarFilter{Currency: "USD", Ranges: []RangeFilter{{Field: "price", Op: rangeLte, Value: 19.99}}},
+			expectedWhere: ` WHERE price_currency = $1 AND price_min <= $2`,
+			expectedArgs:  []any{"USD", int64(1999)},
+		},
+		{
+			name:          "Year Range",
+			filter:        &CarFilter{Ranges: []RangeFilter{{Field: "year", Op: rangeGte, Value: 2020}}},
+			expectedWhere: ` WHERE CASE WHEN in_production THEN 'Infinity'::float8 ELSE end_year END >= $1`,
+			expectedArgs:  []any{int64(2020)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			where, args := tc.filter.where()
+			assert.Equal(t, tc.expectedWhere, where)
+			assert.Equal(t, tc.expectedArgs, args)
+		})
+	}
+}
+
+func TestCarFilterWhereArgTypes(t *testing.T) {
+	// the arguments are bound to the columns compared, which Postgres won't cast a float to
+	// when they're integers
+	testCases := []struct {
+		field        string
+		value        float64
+		expectedType any
+	}{
+		{field: "price", value: 19.99, expectedType: int64(0)},
+		{field: "horsepower", value: 300, expectedType: int64(0)},
+		{field: "year", value: 2015, expectedType: int64(0)},
+		{field: "torque", value: 250.5, expectedType: float64(0)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.field, func(t *testing.T) {
+			filter := &CarFilter{Ranges: []RangeFilter{{Field: tc.field, Op: rangeGte, Value: tc.value}}}
+			_, args := filter.where()
+			if assert.Len(t, args, 1) {
+				assert.IsType(t, tc.expectedType, args[0])
+			}
+		})
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	testCases := []struct {
+		name            string
+		fields          []SortField
+		reversed        bool
+		expectedOrderBy string
+	}{
+		{name: "No Fields", expectedOrderBy: " ORDER BY id ASC NULLS LAST"},
+		{
+			name:            "Several Fields",
+			fields:          []SortField{{Field: "horsepower", Desc: true}, {Field: "company"}},
+			expectedOrderBy: " ORDER BY horsepower_min DESC NULLS LAST, company ASC NULLS LAST, id ASC NULLS LAST",
+		},
+		{
+			name:            "By ID",
+			fields:          []SortField{{Field: "year"}, {Field: "id", Desc: true}, {Field: "model"}},
+			expectedOrderBy: " ORDER BY start_year ASC NULLS LAST, id DESC NULLS LAST",
+		},
+		{
+			name:            "Reversed",
+			fields:          []SortField{{Field: "price", Desc: true}},
+			reversed:        true,
+			expectedOrderBy: " ORDER BY price_min ASC NULLS FIRST, id DESC NULLS FIRST",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedOrderBy, orderBy(tc.fields, tc.reversed))
+		})
+	}
+
+	// every field the API takes has to sort by something
+	for _, field := range sortableFields {
+		assert.Contains(t, sortColumns, field)
+	}
+}
+
+func TestKeyset(t *testing.T) {
+	testCases := []struct {
+		name              string
+		cursor            *Cursor
+		expectedCondition string
+		expectedArgs      []any
+	}{
+		{
+			name:              "By ID",
+			cursor:            &Cursor{Values: []any{7}},
+			expectedCondition: `((id > $2))`,
+			expectedArgs:      []any{"Toyota", 7},
+		},
+		{
+			name:              "Several Fields",
+			cursor:            &Cursor{Sort: []SortField{{Field: "horsepower", Desc: true}}, Values: []any{300.0, 7}},
+			expectedCondition: `(((horsepower_min < $2 OR horsepower_min IS NULL)) OR (horsepower_min = $2 AND id > $3))`,
+			expectedArgs:      []any{"Toyota", 300.0, 7},
+		},
+		{
+			name:              "Missing Value",
+			cursor:            &Cursor{Sort: []SortField{{Field: "price"}}, Values: []any{nil, 7}},
+			expectedCondition: `((price_min IS NULL AND id > $2))`,
+			expectedArgs:      []any{"Toyota", 7},
+		},
+		{
+			name:              "Before",
+			cursor:            &Cursor{Sort: []SortField{{Field: "price"}}, Values: []any{20000.0, 7}, Before: true},
+			expectedCondition: `((price_min < $2) OR (price_min = $2 AND id < $3))`,
+			expectedArgs:      []any{"Toyota", 20000.0, 7},
+		},
+		{
+			name:              "Before Missing Value",
+			cursor:            &Cursor{Sort: []SortField{{Field: "price"}}, Values: []any{nil, 7}, Before: true},
+			expectedCondition: `((price_min IS NOT NULL) OR (price_min IS NULL AND id < $2))`,
+			expectedArgs:      []any{"Toyota", 7},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// the args of the filter come first
+			condition, args := keyset(tc.cursor, []any{"Toyota"})
+			assert.Equal(t, tc.expectedCondition, condition)
+			assert.Equal(t, tc.expectedArgs, args)
+		})
+	}
+}
+
+func TestCarColumnDefinitions(t *testing.T) {
+	// the table is created, and existing ones migrated, from the definitions, so they have to
+	// cover every column that's selected and inserted
+	names := make([]string, len(carColumnDefinitions))
+	for i, definition := range carColumnDefinitions {
+		names[i] = strings.Fields(definition)[0]
+	}
+	assert.Equal(t, carColumnNames(), names)
+}
+
+func TestCheckColumns(t *testing.T) {
+	big := 1234567.8
+	testCases := []struct {
+		name          string
+		car           *Car
+		expectedError string
+	}{
+		{
+			name: "Fits",
+			car:  &Car{Company: "Toyota", Model: "Corolla", TorqueMinNm: new(float64), EngineDisplacements: []float64{1.8}, Doors: []int64{4}},
+		},
+		{
+			name:          "Too Long",
+			car:           &Car{Company: "Toyota", Model: strings.Repeat("x", 51)},
+			expectedError: "model: longer than 50 characters",
+		},
+		{
+			name:          "Too Big",
+			car:           &Car{Company: "Toyota", TorqueMaxNm: &big, EngineDisplacements: []float64{1.8, 99.96}},
+			expectedError: "torque_max_nm: 1234567.8 is too big, it must be less than 1000000; engine_displacements: 99.96 is too big, it must be less than 100",
+		},
+		{
+			name:          "Integer Overflow",
+			car:           &Car{Company: "Toyota", StartYear: 1 << 40, Cylinders: []int64{1 << 32}},
+			expectedError: "start_year: 1099511627776 is too big for an integer; cylinders: 4294967296 is too big for an integer",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkColumns(tc.car)
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestScanLegacyCar(t *testing.T) {
+	// a car stored before the structured columns were added, which are null for it
+	createdAt := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	legacy := map[string]driver.Value{
+		"company": "Toyota", "model": "Corolla", "horsepower": "139 hp", "torque": "126 lb-ft",
+		"transmission_type": "CVT", "drivetrain": "FWD", "fuel_economy": "31/40 mpg",
+		"number_of_doors": "4", "price": "$20,000", "start_year": int64(2015), "end_year": int64(2020),
+		"body_type": "Sedan", "engine_type": "Inline-4", "number_of_cylinders": "4", "created_at": createdAt,
+	}
+	columns := append([]string{"id"}, carColumnNames()...)
+	values := make([]driver.Value, len(columns))
+	values[0] = int64(1)
+	for i, column := range columns[1:] {
+		values[i+1] = legacy[column]
+	}
+
+	db := sql.OpenDB(rowConnector{columns: columns, values: values})
+	defer db.Close()
+
+	var car Car
+	if assert.NoError(t, scanCar(db.QueryRow("SELECT"), &car)) {
+		assert.Equal(t, 1, car.ID)
+		assert.Equal(t, "Corolla", car.Model)
+		assert.Equal(t, 2015, car.StartYear)
+		assert.Empty(t, car.HorsepowerUnit)
+		assert.Empty(t, car.FuelEconomySourceUnit)
+		assert.Empty(t, car.PriceCurrency)
+		assert.False(t, car.PriceIsStarting)
+		assert.False(t, car.InProduction)
+		assert.Nil(t, car.PriceMin)
+		assert.Equal(t, createdAt, car.CreatedAt)
+	}
+}
+
+// rowConnector is a database/sql driver whose queries all return the same row, so that
+// scanning goes through the same conversions as with Postgres
+type rowConnector struct {
+	columns []string
+	values  []driver.Value
+}
+
+func (c rowConnector) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c rowConnector) Driver() driver.Driver                        { return nil }
+func (c rowConnector) Prepare(string) (driver.Stmt, error)          { return c, nil }
+func (c rowConnector) Close() error                                 { return nil }
+func (c rowConnector) Begin() (driver.Tx, error)                    { return nil, errors.New("not supported") }
+func (c rowConnector) NumInput() int                                { return -1 }
+func (c rowConnector) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (c rowConnector) Query([]driver.Value) (driver.Rows, error) {
+	return &singleRow{rowConnector: c}, nil
+}
+
+type singleRow struct {
+	rowConnector
+	done bool
+}
+
+func (r *singleRow) Columns() []string { return r.columns }
+
+func (r *singleRow) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, r.values)
+	return nil
+}
@@ -25,35 +25,50 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/cars/": {
+        "/admin/imports": {
             "post": {
-                "description": "Takes a car JSON and stores in DB. Returned saved JSON",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Takes a dataset file and imports it in the background, cleaning it the same way as the dataset read at startup. Responds with the import job to poll for its progress",
                 "consumes": [
-                    "application/json"
+                    "multipart/form-data"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "cars"
+                    "admin"
                 ],
-                "summary": "Store a new car",
+                "summary": "Import a dataset file",
                 "parameters": [
                     {
-                        "description": "Car JSON",
-                        "name": "car",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/main.Car"
-                        }
+                        "type": "file",
+                        "description": "dataset file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "csv",
+                            "json",
+                            "ndjson",
+                            "xlsx"
+                        ],
+                        "type": "string",
+                        "description": "format of the file, its extension when not given",
+                        "name": "format",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
-                    "200": {
+                    "202": {
                         "description": "ok",
                         "schema": {
-                            "$ref": "#/definitions/main.Car"
+                            "$ref": "#/definitions/main.ImportJob"
                         }
                     },
                     "400": {
@@ -63,6 +78,13 @@ const docTemplate = `{
                             "additionalProperties": true
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -73,9 +95,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/cars/{id}": {
+        "/admin/imports/{id}": {
             "get": {
-                "description": "Returns the car with the given id",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Responds with the progress of an import job and, once it's done, how many cars were inserted, updated and rejected",
                 "consumes": [
                     "application/json"
                 ],
@@ -83,13 +110,13 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "cars"
+                    "admin"
                 ],
-                "summary": "Get single car by id",
+                "summary": "Get an import job",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "search by id",
+                        "type": "integer",
+                        "description": "import job id",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -99,15 +126,34 @@ const docTemplate = `{
                     "200": {
                         "description": "ok",
                         "schema": {
-                            "$ref": "#/definitions/main.Car"
+                            "$ref": "#/definitions/main.ImportJob"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     }
                 }
             }
         },
-        "/cars/{page}": {
+        "/admin/rejections": {
             "get": {
-                "description": "Responds with the list of all cars as JSON",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Responds with the rows of imported datasets that couldn't be cleaned or stored, along with why",
                 "consumes": [
                     "application/json"
                 ],
@@ -115,25 +161,119 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "cars"
+                    "admin"
                 ],
-                "summary": "Get Cars array",
+                "summary": "Get rejected rows",
                 "responses": {
                     "200": {
                         "description": "ok",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/main.Car"
+                                "$ref": "#/definitions/main.RejectedRow"
                             }
                         }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     }
                 }
             }
         },
-        "/ping": {
+        "/admin/rejections/{id}/retry": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Cleans and stores the rejected row again, removing it from the rejections once it's stored. Fields given in the body replace the ones of the rejected record",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Retry a rejected row",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "rejected row id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "corrected fields of the record",
+                        "name": "record",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/main.CarRecord"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.Car"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/body-types": {
             "get": {
-                "description": "Endpoint to test for liveness. It simply returns \"PONG\"",
+                "description": "Responds with each body style of the vocabulary (along with its synonyms) and the number of cars having it",
                 "consumes": [
                     "application/json"
                 ],
@@ -141,73 +281,1267 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "example"
+                    "cars"
                 ],
-                "summary": "Ping example",
+                "summary": "Get body types",
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "ok",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.BodyTypeCount"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "main.Car": {
-            "type": "object",
-            "properties": {
-                "bodyType": {
-                    "type": "string"
-                },
-                "company": {
-                    "type": "string"
-                },
-                "createdAt": {
-                    "type": "string"
-                },
-                "drivetrain": {
-                    "type": "string"
-                },
-                "endYear": {
-                    "type": "integer"
-                },
-                "engineType": {
-                    "type": "string"
-                },
-                "fuelEconomy": {
-                    "type": "string"
-                },
-                "horsepower": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "integer"
-                },
-                "model": {
-                    "type": "string"
-                },
-                "numberOfCylinders": {
-                    "type": "string"
-                },
-                "numberOfDoors": {
-                    "type": "string"
-                },
-                "price": {
-                    "type": "string"
-                },
-                "startYear": {
-                    "type": "integer"
-                },
-                "torque": {
-                    "type": "string"
-                },
-                "transmissionType": {
-                    "type": "string"
+        },
+        "/cars/": {
+            "post": {
+                "description": "Takes a car JSON and stores in DB. Returned saved JSON",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cars"
+                ],
+                "summary": "Store a new car",
+                "parameters": [
+                    {
+                        "description": "Car JSON",
+                        "name": "car",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.Car"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.Car"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
                 }
             }
+        },
+        "/cars/{id}": {
+            "get": {
+                "description": "Returns the car with the given id",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cars"
+                ],
+                "summary": "Get single car by id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "search by id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "default": "L/100km",
+                        "description": "unit for city/highway/combined fuel economy (L/100km, mpg or kmpl)",
+                        "name": "fuel_economy_unit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "the car as it was at this dataset version",
+                        "name": "version",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.Car"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Takes a car JSON and replaces the car with the given id by it. Responds with the stored car",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cars"
+                ],
+                "summary": "Replace a car",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "car id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Car JSON",
+                        "name": "car",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.Car"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.Car"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes the car with the given id. It's still found at the dataset versions it was part of",
+                "tags": [
+                    "cars"
+                ],
+                "summary": "Delete a car",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "car id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Takes a JSON merge patch (RFC 7386) and applies it to the car with the given id. Fields set to null are cleared. Responds with the stored car",
+                "consumes": [
+                    "application/json",
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cars"
+                ],
+                "summary": "Update fields of a car",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "car id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "fields to change",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.Car"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/cars/{id}/quality": {
+            "get": {
+                "description": "Returns the cleaned cylinder and door counts of the car with the given id along with the data-quality warnings found while cleaning it",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cars"
+                ],
+                "summary": "Get data-quality report of a car",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "search by id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.CarQuality"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/cars/{page}": {
+            "get": {
+                "description": "Responds with the list of all cars as JSON",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cars"
+                ],
+                "summary": "Get Cars array",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "L/100km",
+                        "description": "unit for city/highway/combined fuel economy (L/100km, mpg or kmpl)",
+                        "name": "fuel_economy_unit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only cars offered with this drivetrain (FWD, RWD, AWD or 4WD)",
+                        "name": "drivetrain",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only cars offered with this kind of transmission (manual, automatic, DCT or CVT)",
+                        "name": "transmission",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only cars of this company",
+                        "name": "company",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only cars whose model contains this",
+                        "name": "model",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only cars having this body style",
+                        "name": "body_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "only cars in production during this year, or from/until one with year[gte]/year[lte]",
+                        "name": "year",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "fields to sort by, separated by commas and prefixed with - to sort in descending order (id, company, model, year, price, horsepower, torque or createdAt)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "cursor of the page to list, given instead of a page number (empty for the first page), in which case the cars are wrapped along with the cursors of the next and previous pages",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only cars priced in this currency (ISO 4217 code), required when comparing prices",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "only cars compared to a price in the given currency (also price[eq], price[lt], price[gt] and price[gte]), likewise for horsepower, torque (Nm) and year",
+                        "name": "price[lte]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "cars as they were at this dataset version",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "wrap the cars in an object along with the page, per page, total pages and total (not with cursor)",
+                        "name": "envelope",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Car"
+                            }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "links to the first, previous, next and last pages (no last page with cursor)"
+                            },
+                            "X-Total-Count": {
+                                "type": "int",
+                                "description": "number of cars matching the filters"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/manufacturers": {
+            "get": {
+                "description": "Responds with the list of all manufacturers, along with their aliases, parent group and country, as JSON",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "manufacturers"
+                ],
+                "summary": "Get Manufacturers array",
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Manufacturer"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/manufacturers/{id}/cars": {
+            "get": {
+                "description": "Responds with the list of all cars made by the manufacturer with the given id",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "manufacturers"
+                ],
+                "summary": "Get Cars of a manufacturer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "manufacturer id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "default": "L/100km",
+                        "description": "unit for city/highway/combined fuel economy (L/100km, mpg or kmpl)",
+                        "name": "fuel_economy_unit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Car"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/ping": {
+            "get": {
+                "description": "Endpoint to test for liveness. It simply returns \"PONG\"",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "example"
+                ],
+                "summary": "Ping example",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/versions": {
+            "get": {
+                "description": "Responds with the list of dataset versions, one for each import and for each car written through the API (with api as their source), along with how many cars they have and what changed",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "versions"
+                ],
+                "summary": "Get dataset versions",
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.DatasetVersion"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "main.BodyTypeCount": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "slug": {
+                    "type": "string"
+                },
+                "synonyms": {
+                    "description": "other (lowercased) names the style goes by in the dataset",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "main.Car": {
+            "type": "object",
+            "properties": {
+                "bodyType": {
+                    "type": "string"
+                },
+                "bodyTypes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "company": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "cylinders": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "doors": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "drivetrain": {
+                    "type": "string"
+                },
+                "drivetrains": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "endYear": {
+                    "description": "nil for cars that are still in production",
+                    "type": "integer"
+                },
+                "engineAspirations": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "engineDisplacements": {
+                    "description": "displacements are in litres",
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "engineFuels": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "engineLayouts": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "engineType": {
+                    "type": "string"
+                },
+                "fuelEconomy": {
+                    "type": "string"
+                },
+                "fuelEconomyCity": {
+                    "description": "city, highway and combined economy are stored in L/100km and expressed in FuelEconomyUnit",
+                    "type": "number"
+                },
+                "fuelEconomyCombined": {
+                    "type": "number"
+                },
+                "fuelEconomyHighway": {
+                    "type": "number"
+                },
+                "fuelEconomySourceUnit": {
+                    "type": "string"
+                },
+                "fuelEconomyUnit": {
+                    "type": "string"
+                },
+                "horsepower": {
+                    "type": "string"
+                },
+                "horsepowerMax": {
+                    "type": "integer"
+                },
+                "horsepowerMin": {
+                    "type": "integer"
+                },
+                "horsepowerRpmMax": {
+                    "type": "integer"
+                },
+                "horsepowerRpmMin": {
+                    "type": "integer"
+                },
+                "horsepowerUnit": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "inProduction": {
+                    "type": "boolean"
+                },
+                "manufacturerId": {
+                    "type": "integer"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "numberOfCylinders": {
+                    "type": "string"
+                },
+                "numberOfDoors": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "string"
+                },
+                "priceCurrency": {
+                    "type": "string"
+                },
+                "priceIsStarting": {
+                    "type": "boolean"
+                },
+                "priceMax": {
+                    "type": "integer"
+                },
+                "priceMin": {
+                    "type": "integer"
+                },
+                "startYear": {
+                    "type": "integer"
+                },
+                "torque": {
+                    "type": "string"
+                },
+                "torqueMaxLbFt": {
+                    "type": "number"
+                },
+                "torqueMaxNm": {
+                    "type": "number"
+                },
+                "torqueMinLbFt": {
+                    "type": "number"
+                },
+                "torqueMinNm": {
+                    "type": "number"
+                },
+                "torqueRpmMax": {
+                    "type": "integer"
+                },
+                "torqueRpmMin": {
+                    "type": "integer"
+                },
+                "transmissionType": {
+                    "type": "string"
+                },
+                "transmissions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.Transmission"
+                    }
+                }
+            }
+        },
+        "main.CarQuality": {
+            "type": "object",
+            "properties": {
+                "cylinders": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "doors": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "numberOfCylinders": {
+                    "type": "string"
+                },
+                "numberOfDoors": {
+                    "type": "string"
+                },
+                "warnings": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.Issue"
+                    }
+                }
+            }
+        },
+        "main.CarRecord": {
+            "type": "object",
+            "properties": {
+                "bodyType": {
+                    "type": "string"
+                },
+                "bodyTypes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "company": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "cylinders": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "doors": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "drivetrain": {
+                    "type": "string"
+                },
+                "drivetrains": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "endYear": {
+                    "description": "nil for cars that are still in production",
+                    "type": "integer"
+                },
+                "engineAspirations": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "engineDisplacements": {
+                    "description": "displacements are in litres",
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "engineFuels": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "engineLayouts": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "engineType": {
+                    "type": "string"
+                },
+                "fuelEconomy": {
+                    "type": "string"
+                },
+                "fuelEconomyCity": {
+                    "description": "city, highway and combined economy are stored in L/100km and expressed in FuelEconomyUnit",
+                    "type": "number"
+                },
+                "fuelEconomyCombined": {
+                    "type": "number"
+                },
+                "fuelEconomyHighway": {
+                    "type": "number"
+                },
+                "fuelEconomySourceUnit": {
+                    "type": "string"
+                },
+                "fuelEconomyUnit": {
+                    "type": "string"
+                },
+                "horsepower": {
+                    "type": "string"
+                },
+                "horsepowerMax": {
+                    "type": "integer"
+                },
+                "horsepowerMin": {
+                    "type": "integer"
+                },
+                "horsepowerRpmMax": {
+                    "type": "integer"
+                },
+                "horsepowerRpmMin": {
+                    "type": "integer"
+                },
+                "horsepowerUnit": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "inProduction": {
+                    "type": "boolean"
+                },
+                "manufacturerId": {
+                    "type": "integer"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "modelYearRange": {
+                    "type": "string"
+                },
+                "numberOfCylinders": {
+                    "type": "string"
+                },
+                "numberOfDoors": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "string"
+                },
+                "priceCurrency": {
+                    "type": "string"
+                },
+                "priceIsStarting": {
+                    "type": "boolean"
+                },
+                "priceMax": {
+                    "type": "integer"
+                },
+                "priceMin": {
+                    "type": "integer"
+                },
+                "startYear": {
+                    "type": "integer"
+                },
+                "torque": {
+                    "type": "string"
+                },
+                "torqueMaxLbFt": {
+                    "type": "number"
+                },
+                "torqueMaxNm": {
+                    "type": "number"
+                },
+                "torqueMinLbFt": {
+                    "type": "number"
+                },
+                "torqueMinNm": {
+                    "type": "number"
+                },
+                "torqueRpmMax": {
+                    "type": "integer"
+                },
+                "torqueRpmMin": {
+                    "type": "integer"
+                },
+                "transmissionType": {
+                    "type": "string"
+                },
+                "transmissions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.Transmission"
+                    }
+                }
+            }
+        },
+        "main.CarUpdate": {
+            "type": "object",
+            "properties": {
+                "car": {
+                    "type": "string"
+                },
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.DatasetVersion": {
+            "type": "object",
+            "properties": {
+                "cars": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "inserted": {
+                    "type": "integer"
+                },
+                "rejected": {
+                    "type": "integer"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "unchanged": {
+                    "type": "integer"
+                },
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.ImportJob": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.RowError"
+                    }
+                },
+                "filename": {
+                    "type": "string"
+                },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "processed": {
+                    "type": "integer"
+                },
+                "startedAt": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/main.ImportStatus"
+                },
+                "summary": {
+                    "$ref": "#/definitions/main.ImportSummary"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.ImportStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "running",
+                "succeeded",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "importPending",
+                "importRunning",
+                "importSucceeded",
+                "importFailed"
+            ]
+        },
+        "main.ImportSummary": {
+            "type": "object",
+            "properties": {
+                "inserted": {
+                    "type": "integer"
+                },
+                "rejected": {
+                    "type": "integer"
+                },
+                "unchanged": {
+                    "type": "integer"
+                },
+                "updated": {
+                    "type": "integer"
+                },
+                "updates": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.CarUpdate"
+                    }
+                },
+                "version": {
+                    "description": "dataset version created by the import",
+                    "type": "integer"
+                }
+            }
+        },
+        "main.Issue": {
+            "type": "object",
+            "properties": {
+                "column": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.Manufacturer": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "country": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "parentGroup": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.RejectedRow": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "lineNumber": {
+                    "description": "line of the row in the file, the header being line 1",
+                    "type": "integer"
+                },
+                "record": {
+                    "description": "the CarRecord as it was read, before it was cleaned",
+                    "type": "object"
+                },
+                "sourceFile": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.RowError": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "line": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.Transmission": {
+            "type": "object",
+            "properties": {
+                "gears": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "label": {
+                    "description": "branded name of the transmission (i.e. PDK, DSG or Tiptronic S) if it has one",
+                    "type": "string"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "BearerAuth": {
+            "description": "Admin token, given as \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -4,7 +4,9 @@ import "errors"
 
 var errDbUsernameMissing = errors.New("database username not given or found (usage: --dbuser <user> or DBUSER=<user>)")
 var errDbPasswordMissing = errors.New("database password not given or found (usage: --dbpass <password> or DBPASS=<password>)")
-// var errDbCarNotFound = errors.New()
+var errCarNotFound = errors.New("car not found")
+var errNoHeader = errors.New("no header found in the dataset")
+var errDuplicateCar = errors.New("a car with the same company, model and start year already exists")
 type APIError struct {
 	ErrorCode    int
 	ErrorMessage string
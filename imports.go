@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+// ImportStatus is the stage an import job is at
+type ImportStatus string
+
+const (
+	importPending   ImportStatus = "pending"
+	importRunning   ImportStatus = "running"
+	importSucceeded ImportStatus = "succeeded"
+	importFailed    ImportStatus = "failed"
+)
+
+// ImportJob is an uploaded dataset file being imported in the background. Total is only
+// known once the file has been read
+type ImportJob struct {
+	ID         int            `json:"id"`
+	Filename   string         `json:"filename"`
+	Status     ImportStatus   `json:"status"`
+	Total      int            `json:"total"`
+	Processed  int            `json:"processed"`
+	Summary    *ImportSummary `json:"summary,omitempty"`
+	Errors     []RowError     `json:"errors"`
+	Error      string         `json:"error,omitempty"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	StartedAt  *time.Time     `json:"startedAt,omitempty"`
+	FinishedAt *time.Time     `json:"finishedAt,omitempty"`
+}
+
+// RowError is why a row of an import was rejected
+type RowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// importJobs keeps the import jobs in memory, so they're lost on restart
+type importJobs struct {
+	mu     sync.Mutex
+	jobs   map[int]*ImportJob
+	lastID int
+}
+
+func newImportJobs() *importJobs {
+	return &importJobs{jobs: map[int]*ImportJob{}}
+}
+
+// add registers a pending job for the file
+func (j *importJobs) add(filename string) *ImportJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.lastID++
+	job := &ImportJob{ID: j.lastID, Filename: filename, Status: importPending, Errors: []RowError{}, CreatedAt: time.Now().UTC()}
+	j.jobs[job.ID] = job
+	return job
+}
+
+// get returns a copy of the job so it can be read while the import carries on
+func (j *importJobs) get(id int) (*ImportJob, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job, ok := j.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	copied := *job
+	copied.Errors = append([]RowError{}, job.Errors...)
+	return &copied, true
+}
+
+func (j *importJobs) update(job *ImportJob, update func(*ImportJob)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	update(job)
+}
+
+// run imports the file of the job then removes it, the file being a copy of the upload
+func (j *importJobs) run(job *ImportJob, file *os.File, reader RecordReader, db CarDB, timeout time.Duration) {
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	// the job stays pending while another import runs
+	importing.Lock()
+	defer importing.Unlock()
+
+	j.update(job, func(job *ImportJob) {
+		now := time.Now().UTC()
+		job.Status = importRunning
+		job.StartedAt = &now
+	})
+	log.Info("Starting import", "job", job.ID, "filename", job.Filename)
+
+	summary, err := importRecords(file, reader, job.Filename, db, timeout, func(line, total int, err error) {
+		j.update(job, func(job *ImportJob) {
+			job.Total = total
+			job.Processed++
+			if err != nil {
+				job.Errors = append(job.Errors, RowError{Line: line, Error: err.Error()})
+			}
+		})
+	})
+
+	j.update(job, func(job *ImportJob) {
+		now := time.Now().UTC()
+		job.FinishedAt = &now
+		if err != nil {
+			job.Status = importFailed
+			job.Error = err.Error()
+			return
+		}
+		job.Status = importSucceeded
+		job.Summary = summary
+	})
+	if err != nil {
+		log.Error("Import failed", "job", job.ID, "filename", job.Filename, "err", err)
+	}
+}
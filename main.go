@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"io"
 	"os"
 
 	log "golang.org/x/exp/slog"
@@ -10,11 +14,14 @@ import (
 // Setting up the command line constants
 const (
 	defaultCsvFilePath = "./resources/Car_Models.csv"
-	csvFilePathUsage = "CSV file path (eg. '/etc/api/data.csv)."
+	csvFilePathUsage = "Dataset file path (eg. '/etc/api/data.csv). CSV, JSON, NDJSON and XLSX files are supported. If left empty, the csv filename of the config is used."
 	defaultConfigFilePath = "./config.yml"
 	configFilePathUsage = "Config file path (eg. '/etc/api/config.yml'). Config must be named 'config.yml'."
 	dbUserUsage = "Username for database. If left empty, the program will look for the DBUSER environment variable"
 	dbPasswordUsage = "Password for database. If left empty, the program will look for the DBPASS environment variable"
+	dryRunUsage = "Parse and clean the dataset file, then write a report of the issues found, without starting the API or touching the database."
+	reportPathUsage = "File path the dry run report is written to (eg. './report.json'). If left empty, the report is written to stdout."
+	formatUsage = "Format of the dataset file: csv, json, ndjson or xlsx. If left empty, the format is taken from the file's extension."
 )
 
 var (
@@ -22,20 +29,26 @@ var (
 	csvFilePath string
 	dbUser string
 	dbPass string
+	dryRun bool
+	reportPath string
+	format string
 )
 
 // ensures all flag bindings occur prior to flag.Parse() being called
 func init() {
 	flag.StringVar(&configFilePath, "config", defaultConfigFilePath, configFilePathUsage)
 	flag.StringVar(&configFilePath, "c", defaultConfigFilePath, configFilePathUsage)
-	flag.StringVar(&csvFilePath, "csv", defaultCsvFilePath, csvFilePathUsage)
-	flag.StringVar(&csvFilePath, "data", defaultCsvFilePath, csvFilePathUsage)
+	flag.StringVar(&csvFilePath, "csv", "", csvFilePathUsage)
+	flag.StringVar(&csvFilePath, "data", "", csvFilePathUsage)
 	flag.StringVar(&dbUser, "dbuser", "", dbUserUsage)
 	flag.StringVar(&dbPass, "dbpass", "", dbPasswordUsage)
+	flag.BoolVar(&dryRun, "dry-run", false, dryRunUsage)
+	flag.StringVar(&reportPath, "report", "", reportPathUsage)
+	flag.StringVar(&format, "format", "", formatUsage)
 }
 
-func setLogger(level log.Level) {
-	logger := log.New(log.NewJSONHandler(os.Stdout, &log.HandlerOptions{Level: level}))
+func setLogger(w io.Writer, level log.Level) {
+	logger := log.New(log.NewJSONHandler(w, &log.HandlerOptions{Level: level}))
 	log.SetDefault(logger)
 }
 
@@ -54,6 +67,11 @@ func setLogger(level log.Level) {
 
 //	@host		localhost:9090
 //	@BasePath	/api/v1
+
+//	@securityDefinitions.apikey	BearerAuth
+//	@in							header
+//	@name						Authorization
+//	@description				Admin token, given as "Bearer <token>"
 func main() {	
 	// could place this in init() but it'll cause errors for tests
 	// error: "flag provided but not defined"
@@ -61,6 +79,15 @@ func main() {
 
 	var err error
 
+	// a dry run doesn't need the database, so it doesn't need credentials either
+	if dryRun {
+		if err := dryRunDataset(); err != nil {
+			log.Error("There was an issue with the dry run", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// if credentials aren't given as args, look for them in the env
 	if dbUser == "" && os.Getenv("DBUSER") == "" {
 		log.Error(errDbUsernameMissing.Error())
@@ -77,7 +104,7 @@ func main() {
 		panic(err)
 	}
 
-	setLogger(config.Log.Level)
+	setLogger(os.Stdout, config.Log.Level)
 	
 	store, err := NewPostgresStore(config, &Credentials{dbUser, []byte(dbPass)})
 	if err != nil {
@@ -91,31 +118,111 @@ func main() {
 		panic(err)
 	} 
 
-	// want to check if table has any elements prior to read and populating from csv
-	// if it does we'll assume that it's already been populated with data from csv
-	count, err := store.Count()
+	// manufacturers are seeded before any cars are read so that companies can be resolved
+	manufacturers, err := LoadManufacturers(config.Manufacturers.Filename)
 	if err != nil {
-		log.Error("An error occured while checking for table's count", "err", err)
+		log.Error("There was an issue loading the manufacturers file", "filename", config.Manufacturers.Filename, "err", err)
+		panic(err)
+	}
+	if err := store.SeedManufacturers(context.Background(), manufacturers); err != nil {
+		log.Error("There was an issue seeding the manufacturers table", "err", err)
 		panic(err)
-	} else if count == 0 {
-		log.Info("Populating cars table...")
-		go readCsv(store)
+	}
+
+	// imports are upserts so the dataset is read on every start, picking up any changes to it
+	path := datasetFilePath(config.CSV)
+	log.Info("Populating cars table...")
+	go readDataset(store, path, config.CSV)
+	if config.CSV.Watch {
+		go watchDataset(context.Background(), path, config.CSV.WatchInterval, func() {
+			readDataset(store, path, config.CSV)
+		})
 	}
 
 	api := NewAPIServer(store, config.API, config.Environment)
+	api.csv = config.CSV
 	api.StartRouter()
 }
 
-func readCsv(store *PostGresStore) {
-	f, err := os.Open(csvFilePath)
+// readDataset populates the cars table in the background. Failing to do so is logged
+// rather than panicking since that would take the API down along with it
+func readDataset(store *PostGresStore, path string, config CSVConfig) {
+	reader, err := recordReaderFor(format, path, config)
 	if err != nil {
-		log.Error("Unable to read/open file", "filename", csvFilePath)
-		panic(err)
+		log.Error("Unable to read file", "filename", path, "err", err)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Error("Unable to read/open file", "filename", path, "err", err)
+		return
 	}
 	defer f.Close()
 
-	err = CsvReader(f, store)
+	_, err = ImportFile(f, reader, store, config.Timeout)
 	if err != nil {
-		panic(err)
+		log.Error("There was an issue populating the cars table", "filename", path, "err", err)
+	}
+}
+
+// datasetFilePath returns the dataset file given on the command line, or else the one of the config
+func datasetFilePath(config CSVConfig) string {
+	if csvFilePath != "" {
+		return csvFilePath
+	}
+	if config.Filename != "" {
+		return config.Filename
+	}
+	return defaultCsvFilePath
+}
+
+// dryRunDataset cleans the dataset file and writes the report of the issues found
+func dryRunDataset() error {
+	config, err := LoadConfig(configFilePath)
+	if err != nil {
+		return err
+	}
+	// logging to stderr keeps stdout for the report
+	setLogger(os.Stderr, config.Log.Level)
+
+	manufacturers, err := LoadManufacturers(config.Manufacturers.Filename)
+	if err != nil {
+		return err
+	}
+	// IDs are given by the database, so number them in file order to resolve companies
+	for i, manufacturer := range manufacturers {
+		manufacturer.ID = i + 1
+	}
+
+	path := datasetFilePath(config.CSV)
+	reader, err := recordReaderFor(format, path, config.CSV)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	report, err := DryRun(f, reader, manufacturers)
+	if err != nil {
+		return err
+	}
+	log.Info("Dry run finished", "rows", report.Rows, "valid", report.Valid, "invalid", report.Invalid, "warnings", report.Warnings)
+	return writeReport(report, reportPath)
+}
+
+// writeReport writes the report as JSON to the given path, or stdout if it's empty
+func writeReport(report *ImportReport, path string) error {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		_, err = fmt.Fprintln(os.Stdout, string(b))
+		return err
 	}
+	return os.WriteFile(path, b, 0644)
 }
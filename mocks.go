@@ -2,11 +2,19 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 var cars []*Car
+var rejectedRows []*RejectedRow
+var versions = []*DatasetVersion{
+	{ID: 1, Source: "Car_Models.csv", Cars: 1, Inserted: 1},
+	{ID: 2, Source: "Car_Models.csv", Cars: 3, Inserted: 2, Unchanged: 1},
+}
 
 type MockDB struct{}
 
@@ -14,29 +22,233 @@ func (m *MockDB) CreateCar(c context.Context, car *Car) (int, error) {
 	if car.Company == "BadCompany" {
 		return 0, fmt.Errorf("Error")
 	}
+	if car.Company == "DuplicateCompany" {
+		return 0, errDuplicateCar
+	}
 	cars = append(cars, car)
 	return 1, nil
 }
 
-func (m *MockDB) GetCars(context.Context, *Pagination) ([]*Car, error) {
+func (m *MockDB) GetCars(ctx context.Context, page *Pagination, filter *CarFilter) ([]*Car, error) {
 	cars := []*Car{
 		{ID: 1, Company: "Toyota", Model: "Corolla"},
 		{ID: 2, Company: "Ford", Model: "F150"},
 		{ID: 3, Company: "Chevrolet", Model: "Cobalt"},
 	}
-	return cars, nil
+	if filter != nil && filter.Version == 1 {
+		// only the first car was there at the first version
+		cars = cars[:1]
+	}
+	if filter != nil {
+		cars = m.filterCars(ctx, cars, filter)
+	}
+	if page == nil {
+		return cars, nil
+	}
+
+	sortCars(cars, page.Sort)
+	from, to := int(page.Offset), int(page.Offset+page.Limit)
+	if page.Cursor != nil {
+		// the cursor's last value is the ID of the car it points at
+		id := page.Cursor.Values[len(page.Cursor.Values)-1]
+		for i, car := range cars {
+			if car.ID == id && page.Cursor.Before {
+				from, to = i-int(page.Limit), i
+			} else if car.ID == id {
+				from, to = i+1, i+1+int(page.Limit)
+			}
+		}
+	}
+	if from < 0 {
+		from = 0
+	}
+	if to > len(cars) {
+		to = len(cars)
+	}
+	if from > to {
+		return []*Car{}, nil
+	}
+	return cars[from:to], nil
 }
 
-func (m *MockDB) GetCarById(c context.Context, id string) (*Car, error) {
+func (m *MockDB) filterCars(ctx context.Context, cars []*Car, filter *CarFilter) []*Car {
+	manufacturers, _ := m.GetManufacturers(ctx)
+	index := newManufacturerIndex(manufacturers)
+	filtered := []*Car{}
+	for _, car := range cars {
+		if filter.ManufacturerID != 0 {
+			index.resolve(car)
+			if car.ManufacturerID == nil || *car.ManufacturerID != filter.ManufacturerID {
+				continue
+			}
+		}
+		if filter.Company != "" && !strings.EqualFold(car.Company, filter.Company) {
+			continue
+		}
+		if filter.Model != "" && !strings.Contains(strings.ToLower(car.Model), strings.ToLower(filter.Model)) {
+			continue
+		}
+		filtered = append(filtered, car)
+	}
+	return filtered
+}
+
+func (m *MockDB) GetCarById(c context.Context, id string, version int) (*Car, error) {
 	var car *Car
 	if id == "1" {
 		i, _ := strconv.Atoi(id)
 		car = &Car{ID: i, Company: "Toyota", Model: "Corolla"}
 		return car, nil
 	}
-	return nil, fmt.Errorf("car not found: %s", id)
+	return nil, fmt.Errorf("%w: %s", errCarNotFound, id)
+}
+
+func (m *MockDB) UpdateCar(c context.Context, car *Car) error {
+	if car.ID != 1 {
+		return fmt.Errorf("%w: %d", errCarNotFound, car.ID)
+	}
+	if car.Company == "DuplicateCompany" {
+		return errDuplicateCar
+	}
+	return nil
+}
+
+func (m *MockDB) DeleteCar(c context.Context, id string) error {
+	if id != "1" {
+		return fmt.Errorf("%w: %s", errCarNotFound, id)
+	}
+	return nil
+}
+
+func (m *MockDB) Count(ctx context.Context, filter *CarFilter) (int, error) {
+	cars, _ := m.GetCars(ctx, nil, filter)
+	return len(cars), nil
+}
+func (m *MockDB) GetManufacturers(context.Context) ([]*Manufacturer, error) {
+	manufacturers := []*Manufacturer{
+		{ID: 1, Name: "Toyota", ParentGroup: "Toyota Motor Corporation", Country: "Japan"},
+		{ID: 2, Name: "Opel", Aliases: []string{"Opel/Vauxhall"}, ParentGroup: "Stellantis", Country: "Germany"},
+	}
+	return manufacturers, nil
+}
+
+func (m *MockDB) GetManufacturerById(ctx context.Context, id string) (*Manufacturer, error) {
+	manufacturers, _ := m.GetManufacturers(ctx)
+	for _, manufacturer := range manufacturers {
+		if strconv.Itoa(manufacturer.ID) == id {
+			return manufacturer, nil
+		}
+	}
+	return nil, fmt.Errorf("manufacturer not found: %s", id)
 }
 
-func (m *MockDB) Count() (int, error) {
-	return 0, nil
-}
\ No newline at end of file
+func (m *MockDB) CountBodyTypes(context.Context) (map[string]int, error) {
+	return map[string]int{"sedan": 2, "pickup": 1}, nil
+}
+
+func (m *MockDB) ImportCars(c context.Context, source string, importedCars []*Car, rejected []*RejectedRow) (*ImportSummary, error) {
+	// nothing is stored when a car can't be, the same as a rolled back transaction. Like the
+	// database, cars that don't fit the columns of the cars table can't be
+	for _, car := range importedCars {
+		if car.Company == "BadCompany" {
+			return nil, fmt.Errorf("Error")
+		}
+		if err := checkColumns(car); err != nil {
+			return nil, err
+		}
+	}
+
+	summary := &ImportSummary{Rejected: len(rejected)}
+	for _, car := range importedCars {
+		i := indexOfCar(car.naturalKey())
+		if i < 0 {
+			cars = append(cars, car)
+			summary.Inserted++
+			continue
+		}
+		changes, err := cars[i].Diff(car)
+		if err != nil {
+			return nil, err
+		}
+		if len(changes) == 0 {
+			summary.Unchanged++
+			continue
+		}
+		car.ID = cars[i].ID
+		cars[i] = car
+		summary.addUpdate(car, changes)
+	}
+
+	kept := rejectedRows[:0]
+	for _, row := range rejectedRows {
+		if row.SourceFile != source {
+			kept = append(kept, row)
+		}
+	}
+	rejectedRows = append(kept, rejected...)
+	return summary, nil
+}
+
+func indexOfCar(key naturalKey) int {
+	for i, car := range cars {
+		if car.naturalKey() == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *MockDB) GetRejectedRows(context.Context) ([]*RejectedRow, error) {
+	rows := []*RejectedRow{
+		{ID: 1, SourceFile: "cars.csv", LineNumber: 2, Record: json.RawMessage(`{"company": "Toyota", "model": "Corolla", "horsepower": "lots", "modelYearRange": "2015 - 2020"}`), Error: `Horsepower: no numeric value found in "lots"`},
+	}
+	return rows, nil
+}
+
+func (m *MockDB) GetRejectedRowById(ctx context.Context, id string) (*RejectedRow, error) {
+	rows, _ := m.GetRejectedRows(ctx)
+	for _, row := range rows {
+		if strconv.Itoa(row.ID) == id {
+			return row, nil
+		}
+	}
+	return nil, fmt.Errorf("rejected row not found: %s", id)
+}
+
+func (m *MockDB) DeleteRejectedRow(context.Context, int) error {
+	return nil
+}
+
+func (m *MockDB) GetVersions(context.Context) ([]*DatasetVersion, error) {
+	return versions, nil
+}
+
+func (m *MockDB) GetVersion(ctx context.Context, id int) (*DatasetVersion, error) {
+	for _, version := range versions {
+		if version.ID == id {
+			return version, nil
+		}
+	}
+	return nil, fmt.Errorf("dataset version not found: %d", id)
+}
+
+// sortCars sorts the cars the way orderBy does, for the fields the mocked cars have
+func sortCars(cars []*Car, fields []SortField) {
+	sort.SliceStable(cars, func(i, j int) bool {
+		for _, field := range fields {
+			var less, greater bool
+			switch field.Field {
+			case "company":
+				less, greater = cars[i].Company < cars[j].Company, cars[i].Company > cars[j].Company
+			case "model":
+				less, greater = cars[i].Model < cars[j].Model, cars[i].Model > cars[j].Model
+			case "id":
+				less, greater = cars[i].ID < cars[j].ID, cars[i].ID > cars[j].ID
+			}
+			if less || greater {
+				return less != field.Desc
+			}
+		}
+		return cars[i].ID < cars[j].ID
+	})
+}
@@ -0,0 +1,564 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/exp/slices"
+	log "golang.org/x/exp/slog"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Supported dataset formats
+const (
+	formatCSV    = "csv"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatXLSX   = "xlsx"
+)
+
+// RecordReader reads the car records of a dataset. Each record's Line is where it was found
+// in the dataset so that rows can be reported back to whoever has to fix them
+type RecordReader interface {
+	Read(r io.Reader) ([]*CarRecord, error)
+}
+
+// NewRecordReader returns the reader of the given format. CSV files are read as configured
+// and Excel sheets have their columns mapped the same way
+func NewRecordReader(format string, config CSVConfig) (RecordReader, error) {
+	switch strings.ToLower(format) {
+	case formatCSV:
+		return newCSVRecordReader(config)
+	case formatJSON:
+		return jsonRecordReader{}, nil
+	case formatNDJSON:
+		return ndjsonRecordReader{}, nil
+	case formatXLSX:
+		columns, err := newColumnMapping(config.Columns)
+		if err != nil {
+			return nil, err
+		}
+		return xlsxRecordReader{columns: columns}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q, must be one of %s, %s, %s, %s", format, formatCSV, formatJSON, formatNDJSON, formatXLSX)
+	}
+}
+
+// recordReaderFor returns the reader of the format, or of the file's extension when no
+// format is given
+func recordReaderFor(format, filename string, config CSVConfig) (RecordReader, error) {
+	if format != "" {
+		return NewRecordReader(format, config)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".jsonl":
+		return ndjsonRecordReader{}, nil
+	case "":
+		return nil, fmt.Errorf("no format given and %q has no extension", filename)
+	default:
+		return NewRecordReader(strings.TrimPrefix(ext, "."), config)
+	}
+}
+
+// csvField is a field of the car records read from a column
+type csvField struct {
+	// JSON name of the field, which it's mapped by
+	name string
+	// header of the column in the Kaggle dataset
+	header string
+	value  func(*CarRecord) *string
+}
+
+var csvFields = []csvField{
+	{"company", columnCompany, func(r *CarRecord) *string { return &r.Company }},
+	{"model", columnModel, func(r *CarRecord) *string { return &r.Model }},
+	{"horsepower", columnHorsepower, func(r *CarRecord) *string { return &r.Horsepower }},
+	{"torque", columnTorque, func(r *CarRecord) *string { return &r.Torque }},
+	{"transmissionType", columnTransmissionType, func(r *CarRecord) *string { return &r.TransmissionType }},
+	{"drivetrain", columnDrivetrain, func(r *CarRecord) *string { return &r.Drivetrain }},
+	{"fuelEconomy", columnFuelEconomy, func(r *CarRecord) *string { return &r.FuelEconomy }},
+	{"numberOfDoors", columnNumberOfDoors, func(r *CarRecord) *string { return &r.NumberOfDoors }},
+	{"price", columnPrice, func(r *CarRecord) *string { return &r.Price }},
+	{"modelYearRange", columnModelYearRange, func(r *CarRecord) *string { return &r.ModelYearRange }},
+	{"bodyType", columnBodyType, func(r *CarRecord) *string { return &r.BodyType }},
+	{"engineType", columnEngineType, func(r *CarRecord) *string { return &r.EngineType }},
+	{"numberOfCylinders", columnNumberOfCylinders, func(r *CarRecord) *string { return &r.NumberofCylinders }},
+}
+
+// requiredFields can't be left out since cars are told apart by them
+var requiredFields = []string{"company", "model", "modelYearRange"}
+
+// columnMapping is the header each field is read from, keyed by the field's name in lower
+// case since that's how viper hands over the keys of a map. Fields that aren't mapped are
+// read from their column in the Kaggle dataset and mapping one to an empty header leaves it out
+type columnMapping map[string]string
+
+func newColumnMapping(columns map[string]string) (columnMapping, error) {
+	mapping := columnMapping{}
+	for name, header := range columns {
+		mapping[strings.ToLower(name)] = strings.TrimSpace(header)
+	}
+
+	for name := range mapping {
+		if !slices.ContainsFunc(csvFields, func(field csvField) bool { return strings.ToLower(field.name) == name }) {
+			return nil, fmt.Errorf("unknown field %q in the column mapping", name)
+		}
+	}
+	for _, name := range requiredFields {
+		if header, ok := mapping[strings.ToLower(name)]; ok && header == "" {
+			return nil, fmt.Errorf("field %s can't be left out of the column mapping", name)
+		}
+	}
+	return mapping, nil
+}
+
+func (m columnMapping) header(field csvField) string {
+	if header, ok := m[strings.ToLower(field.name)]; ok {
+		return header
+	}
+	return field.header
+}
+
+// records reads the rows under the header into car records, with lines being the line of
+// each row. Headers are matched regardless of case and surrounding spaces. The columns of
+// required fields, and the ones mapped in the config, have to be found while the other
+// columns of the Kaggle dataset are left empty when missing
+func (m columnMapping) records(header []string, rows [][]string, lines []int) ([]*CarRecord, error) {
+	positions := make(map[string]int, len(header))
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if _, ok := positions[h]; !ok {
+			positions[h] = i
+		}
+	}
+
+	columns := make(map[int]csvField, len(csvFields))
+	var missing []string
+	for _, field := range csvFields {
+		h := m.header(field)
+		if h == "" {
+			continue
+		}
+		position, ok := positions[strings.ToLower(h)]
+		if !ok {
+			_, mapped := m[strings.ToLower(field.name)]
+			if mapped || slices.Contains(requiredFields, field.name) {
+				missing = append(missing, fmt.Sprintf("%q (%s)", h, field.name))
+			} else {
+				log.Warn("Column not found, its field is left empty", "column", h, "field", field.name)
+			}
+			continue
+		}
+		columns[position] = field
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing columns %s, found %q", strings.Join(missing, ", "), header)
+	}
+
+	carRecords := make([]*CarRecord, 0, len(rows))
+	for i, row := range rows {
+		carRecord := &CarRecord{Car: &Car{}, Line: lines[i]}
+		for position, field := range columns {
+			if position < len(row) {
+				*field.value(carRecord) = row[position]
+			}
+		}
+		carRecords = append(carRecords, carRecord)
+	}
+	return carRecords, nil
+}
+
+// csvRecordReader reads CSV files. Its zero value reads UTF-8 files separated by commas,
+// with the headers of the Kaggle dataset on the first line
+type csvRecordReader struct {
+	delimiter rune
+	// nil for UTF-8
+	encoding encoding.Encoding
+	// line of the header, the lines above it are skipped
+	headerRow int
+	columns   columnMapping
+}
+
+func newCSVRecordReader(config CSVConfig) (csvRecordReader, error) {
+	var reader csvRecordReader
+
+	if config.Delimiter != "" {
+		delimiter := []rune(config.Delimiter)
+		if len(delimiter) != 1 || delimiter[0] == '"' || delimiter[0] == '\r' || delimiter[0] == '\n' || delimiter[0] == utf8.RuneError {
+			return reader, fmt.Errorf("invalid delimiter %q, must be a single character", config.Delimiter)
+		}
+		reader.delimiter = delimiter[0]
+	}
+
+	if config.Encoding != "" {
+		e, err := htmlindex.Get(config.Encoding)
+		if err != nil {
+			return reader, fmt.Errorf("unsupported encoding %q", config.Encoding)
+		}
+		if e != unicode.UTF8 {
+			reader.encoding = e
+		}
+	}
+
+	if config.HeaderRow < 0 {
+		return reader, fmt.Errorf("invalid header row %d", config.HeaderRow)
+	}
+	reader.headerRow = config.HeaderRow
+
+	columns, err := newColumnMapping(config.Columns)
+	if err != nil {
+		return reader, err
+	}
+	reader.columns = columns
+	return reader, nil
+}
+
+func (c csvRecordReader) Read(r io.Reader) ([]*CarRecord, error) {
+	if c.encoding != nil {
+		r = transform.NewReader(r, c.encoding.NewDecoder())
+	}
+	reader := csv.NewReader(r)
+	if c.delimiter != 0 {
+		reader.Comma = c.delimiter
+	}
+	// the lines above the header don't have to be as wide as it, the rows under it do
+	reader.FieldsPerRecord = -1
+
+	headerRow := c.headerRow
+	if headerRow == 0 {
+		headerRow = 1
+	}
+
+	var (
+		header []string
+		rows   [][]string
+		lines  []int
+		// the rows that aren't as wide as the header, by index
+		errs = map[int]error{}
+	)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		// a row's line is where it starts, since quoted values can span lines
+		line, _ := reader.FieldPos(0)
+		switch {
+		case line < headerRow:
+			continue
+		case header == nil:
+			header = row
+			continue
+		case len(row) != len(header):
+			// the row is rejected on its own rather than failing the whole file
+			errs[len(rows)] = fmt.Errorf("%w, expected %d but found %d", csv.ErrFieldCount, len(header), len(row))
+		}
+		rows = append(rows, row)
+		lines = append(lines, line)
+	}
+	if header == nil {
+		return nil, errNoHeader
+	}
+
+	carRecords, err := c.columns.records(header, rows, lines)
+	if err != nil {
+		return nil, err
+	}
+	for i, err := range errs {
+		carRecords[i].Err = err
+	}
+	return carRecords, nil
+}
+
+// jsonRecordReader reads a JSON array of records, named the same as the cars of the API
+type jsonRecordReader struct{}
+
+func (jsonRecordReader) Read(r io.Reader) ([]*CarRecord, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('[') {
+		return nil, errors.New("expected a JSON array of records")
+	}
+
+	carRecords := []*CarRecord{}
+	for decoder.More() {
+		line := lineAt(data, decoder.InputOffset())
+		carRecord := &CarRecord{Car: &Car{}}
+		if err := decoder.Decode(carRecord); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		carRecord.Line = line
+		carRecords = append(carRecords, carRecord)
+	}
+	return carRecords, nil
+}
+
+// lineAt returns the line of the next value after the offset, skipping the separators
+// between the values of an array
+func lineAt(data []byte, offset int64) int {
+	for offset < int64(len(data)) && strings.ContainsRune(" \t\r\n,", rune(data[offset])) {
+		offset++
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// ndjsonRecordReader reads one JSON record per line. Blank lines are skipped
+type ndjsonRecordReader struct{}
+
+func (ndjsonRecordReader) Read(r io.Reader) ([]*CarRecord, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	carRecords := []*CarRecord{}
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		carRecord := &CarRecord{Car: &Car{}}
+		if err := json.Unmarshal(scanner.Bytes(), carRecord); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		carRecord.Line = line
+		carRecords = append(carRecords, carRecord)
+	}
+	return carRecords, scanner.Err()
+}
+
+// xlsxRecordReader reads the first sheet of an Excel workbook, with its header in the first
+// row and its columns mapped the same as CSV files. The line of a record is its row number
+// in the sheet
+type xlsxRecordReader struct {
+	columns columnMapping
+}
+
+func (x xlsxRecordReader) Read(r io.Reader) ([]*CarRecord, error) {
+	// workbooks are zip files, which can't be read as a stream
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	workbook, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not an xlsx file: %w", err)
+	}
+
+	rows, lines, err := readFirstSheet(workbook)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errNoHeader
+	}
+	return x.columns.records(rows[0], rows[1:], lines[1:])
+}
+
+// xlsxText is the text of a shared string or inline string cell, which is either plain or
+// made of runs of rich text
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	var b strings.Builder
+	b.WriteString(t.Text)
+	for _, run := range t.Runs {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RelationshipID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Number int `xml:"r,attr"`
+		Cells  []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readFirstSheet returns the rows of the workbook's first sheet with the row number of
+// each. Rows without any values are left out
+func readFirstSheet(workbook *zip.Reader) ([][]string, []int, error) {
+	sheetPath, err := firstSheetPath(workbook)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var sharedStrings xlsxSharedStrings
+	if err := readXML(workbook, "xl/sharedStrings.xml", &sharedStrings); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, err
+	}
+
+	var sheet xlsxSheet
+	if err := readXML(workbook, sheetPath, &sheet); err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		rows   [][]string
+		lines  []int
+		number int
+	)
+	for _, row := range sheet.Rows {
+		// row and cell references are optional, in which case they follow the previous ones
+		number++
+		if row.Number > 0 {
+			number = row.Number
+		}
+
+		var values []string
+		empty := true
+		for _, cell := range row.Cells {
+			column := len(values)
+			if cell.Ref != "" {
+				if column, err = columnIndex(cell.Ref); err != nil {
+					return nil, nil, fmt.Errorf("row %d: %w", number, err)
+				}
+			}
+
+			value := cell.Value
+			switch cell.Type {
+			case "s":
+				i, err := strconv.Atoi(cell.Value)
+				if err != nil || i < 0 || i >= len(sharedStrings.Items) {
+					return nil, nil, fmt.Errorf("row %d: invalid shared string %q", number, cell.Value)
+				}
+				value = sharedStrings.Items[i].String()
+			case "inlineStr":
+				value = cell.Inline.String()
+			}
+
+			for len(values) <= column {
+				values = append(values, "")
+			}
+			values[column] = value
+			empty = empty && value == ""
+		}
+
+		if !empty {
+			rows = append(rows, values)
+			lines = append(lines, number)
+		}
+	}
+
+	// every row is as wide as the widest so that missing cells read as empty
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	for i := range rows {
+		for len(rows[i]) < width {
+			rows[i] = append(rows[i], "")
+		}
+	}
+	return rows, lines, nil
+}
+
+// firstSheetPath finds where the first sheet of the workbook is stored
+func firstSheetPath(workbook *zip.Reader) (string, error) {
+	var book xlsxWorkbook
+	if err := readXML(workbook, "xl/workbook.xml", &book); err != nil {
+		return "", fmt.Errorf("not an xlsx file: %w", err)
+	}
+	if len(book.Sheets) == 0 {
+		return "", errors.New("workbook has no sheets")
+	}
+
+	var rels xlsxRelationships
+	if err := readXML(workbook, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID != book.Sheets[0].RelationshipID {
+			continue
+		}
+		// targets are relative to the workbook unless they're absolute
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "", errors.New("first sheet of the workbook not found")
+}
+
+func readXML(workbook *zip.Reader, name string, v any) error {
+	f, err := workbook.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return xml.NewDecoder(f).Decode(v)
+}
+
+// maxXlsxColumns is the number of columns of an Excel sheet, the last one being XFD
+const maxXlsxColumns = 16384
+
+// columnIndex returns the zero-based column of a cell reference (i.e. 27 for "AB3")
+func columnIndex(ref string) (int, error) {
+	column := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		column = column*26 + int(r-'A'+1)
+		if column > maxXlsxColumns {
+			return 0, fmt.Errorf("cell reference %q is past the last column", ref)
+		}
+	}
+	if column == 0 {
+		return 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+	return column - 1, nil
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordReaders(t *testing.T) {
+	testCases := []struct {
+		name              string
+		reader            RecordReader
+		content           []byte
+		expectedModels    []string
+		expectedLines     []int
+		expectedYearRange string
+		expectedError     bool
+	}{
+		{
+			name:   "CSV",
+			reader: csvRecordReader{},
+			content: []byte(`Company,Model,Horsepower,Model Year Range
+Ferrari,Roma,612 hp,2020 - Present
+Toyota,Corolla,139 hp,2015 - 2020
+`),
+			expectedModels:    []string{"Roma", "Corolla"},
+			expectedLines:     []int{2, 3},
+			expectedYearRange: "2020 - Present",
+		},
+		{
+			name:   "JSON",
+			reader: jsonRecordReader{},
+			content: []byte(`[
+  {
+    "company": "Ferrari",
+    "model": "Roma",
+    "horsepower": "612 hp",
+    "modelYearRange": "2020 - Present"
+  },
+  {"company": "Toyota", "model": "Corolla", "horsepower": "139 hp", "modelYearRange": "2015 - 2020"}
+]`),
+			expectedModels:    []string{"Roma", "Corolla"},
+			expectedLines:     []int{2, 8},
+			expectedYearRange: "2020 - Present",
+		},
+		{
+			name:          "JSON Object",
+			reader:        jsonRecordReader{},
+			content:       []byte(`{"company": "Ferrari", "model": "Roma"}`),
+			expectedError: true,
+		},
+		{
+			name:   "NDJSON",
+			reader: ndjsonRecordReader{},
+			content: []byte(`{"company": "Ferrari", "model": "Roma", "horsepower": "612 hp", "modelYearRange": "2020 - Present"}
+
+{"company": "Toyota", "model": "Corolla", "horsepower": "139 hp", "modelYearRange": "2015 - 2020"}
+`),
+			expectedModels:    []string{"Roma", "Corolla"},
+			expectedLines:     []int{1, 3},
+			expectedYearRange: "2020 - Present",
+		},
+		{
+			name:   "Invalid NDJSON",
+			reader: ndjsonRecordReader{},
+			content: []byte(`{"company": "Ferrari", "model": "Roma"}
+{"company": "Toyota", "model":
+`),
+			expectedError: true,
+		},
+		{
+			name:              "XLSX",
+			reader:            xlsxRecordReader{},
+			content:           testWorkbook(t),
+			expectedModels:    []string{"Roma", "Corolla"},
+			expectedLines:     []int{2, 4},
+			expectedYearRange: "2020 - Present",
+		},
+		{
+			name:          "Not An XLSX File",
+			reader:        xlsxRecordReader{},
+			content:       []byte("Company,Model\nFerrari,Roma\n"),
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			carRecords, err := tc.reader.Read(bytes.NewReader(tc.content))
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			var models []string
+			var lines []int
+			for _, carRecord := range carRecords {
+				models = append(models, carRecord.Model)
+				lines = append(lines, carRecord.Line)
+			}
+			assert.Equal(t, tc.expectedModels, models)
+			assert.Equal(t, tc.expectedLines, lines)
+			assert.Equal(t, "Ferrari", carRecords[0].Company)
+			assert.Equal(t, "612 hp", carRecords[0].Horsepower)
+			assert.Equal(t, tc.expectedYearRange, carRecords[0].ModelYearRange)
+		})
+	}
+}
+
+func TestCsvRecordReaderConfig(t *testing.T) {
+	testCases := []struct {
+		name          string
+		config        CSVConfig
+		content       []byte
+		expectedCar   *CarRecord
+		expectedError string
+	}{
+		{
+			name: "Renamed Headers",
+			config: CSVConfig{Columns: map[string]string{
+				"company":        "Make",
+				"modelyearrange": "Years",
+				"horsepower":     "Power",
+			}},
+			content:     []byte("Make,Model,Power,Years\nFerrari,Roma,612 hp,2020 - Present\n"),
+			expectedCar: &CarRecord{Car: &Car{Company: "Ferrari", Model: "Roma", Horsepower: "612 hp"}, ModelYearRange: "2020 - Present", Line: 2},
+		},
+		{
+			name:        "Headers Regardless Of Case",
+			content:     []byte("\ufeffcompany, MODEL ,model year range\nFerrari,Roma,2020 - Present\n"),
+			expectedCar: &CarRecord{Car: &Car{Company: "Ferrari", Model: "Roma"}, ModelYearRange: "2020 - Present", Line: 2},
+		},
+		{
+			name:          "Missing Column",
+			config:        CSVConfig{Columns: map[string]string{"horsepower": "Power"}},
+			content:       []byte("Company,Model,Horsepower,Model Year Range\nFerrari,Roma,612 hp,2020 - Present\n"),
+			expectedError: `missing columns "Power" (horsepower), found ["Company" "Model" "Horsepower" "Model Year Range"]`,
+		},
+		{
+			name:        "Left Out Column",
+			config:      CSVConfig{Columns: map[string]string{"horsepower": ""}},
+			content:     []byte("Company,Model,Model Year Range\nFerrari,Roma,2020 - Present\n"),
+			expectedCar: &CarRecord{Car: &Car{Company: "Ferrari", Model: "Roma"}, ModelYearRange: "2020 - Present", Line: 2},
+		},
+		{
+			name:        "Semicolons",
+			config:      CSVConfig{Delimiter: ";"},
+			content:     []byte("Company;Model;Price;Model Year Range\nFerrari;Roma;\"$222,620\";2020 - Present\n"),
+			expectedCar: &CarRecord{Car: &Car{Company: "Ferrari", Model: "Roma", Price: "$222,620"}, ModelYearRange: "2020 - Present", Line: 2},
+		},
+		{
+			name:        "Windows-1252",
+			config:      CSVConfig{Encoding: "windows-1252"},
+			content:     []byte("Company,Model,Model Year Range\nCitro\xebn,C5 A\xefrcross,2019 - Present\n"),
+			expectedCar: &CarRecord{Car: &Car{Company: "Citroën", Model: "C5 Aïrcross"}, ModelYearRange: "2019 - Present", Line: 2},
+		},
+		{
+			name:        "Header Row",
+			config:      CSVConfig{HeaderRow: 3},
+			content:     []byte("Vendor update\nExported 2023-06-01, 1 car\nCompany,Model,Model Year Range\nFerrari,Roma,2020 - Present\n"),
+			expectedCar: &CarRecord{Car: &Car{Company: "Ferrari", Model: "Roma"}, ModelYearRange: "2020 - Present", Line: 4},
+		},
+		{
+			name:    "Row Wider Than Header",
+			content: []byte("Company,Model,Model Year Range\nFerrari,Roma,2020 - Present,612 hp\n"),
+			expectedCar: &CarRecord{
+				Car:            &Car{Company: "Ferrari", Model: "Roma"},
+				ModelYearRange: "2020 - Present",
+				Line:           2,
+				Err:            fmt.Errorf("%w, expected 3 but found 4", csv.ErrFieldCount),
+			},
+		},
+		{
+			name:          "Empty File",
+			content:       []byte(""),
+			expectedError: errNoHeader.Error(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reader, err := newCSVRecordReader(tc.config)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			carRecords, err := reader.Read(bytes.NewReader(tc.content))
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			if assert.NoError(t, err) && assert.Len(t, carRecords, 1) {
+				assert.Equal(t, tc.expectedCar, carRecords[0])
+			}
+		})
+	}
+}
+
+func TestNewCsvRecordReader(t *testing.T) {
+	testCases := []struct {
+		name          string
+		config        CSVConfig
+		expectedError string
+	}{
+		{name: "Defaults", config: CSVConfig{}},
+		{name: "Tabs", config: CSVConfig{Delimiter: "\t", Encoding: "utf-8", HeaderRow: 1}},
+		{name: "Invalid Delimiter", config: CSVConfig{Delimiter: ";;"}, expectedError: `invalid delimiter ";;", must be a single character`},
+		{name: "Unsupported Encoding", config: CSVConfig{Encoding: "klingon"}, expectedError: `unsupported encoding "klingon"`},
+		{name: "Invalid Header Row", config: CSVConfig{HeaderRow: -1}, expectedError: "invalid header row -1"},
+		{name: "Unknown Field", config: CSVConfig{Columns: map[string]string{"colour": "Color"}}, expectedError: `unknown field "colour" in the column mapping`},
+		{name: "Required Field Left Out", config: CSVConfig{Columns: map[string]string{"model": ""}}, expectedError: "field model can't be left out of the column mapping"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newCSVRecordReader(tc.config)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestRecordReaderFor(t *testing.T) {
+	testCases := []struct {
+		name           string
+		format         string
+		filename       string
+		expectedReader RecordReader
+		expectedError  bool
+	}{
+		{name: "CSV Extension", filename: "cars.csv", expectedReader: csvRecordReader{}},
+		{name: "JSON Extension", filename: "cars.JSON", expectedReader: jsonRecordReader{}},
+		{name: "NDJSON Extension", filename: "cars.ndjson", expectedReader: ndjsonRecordReader{}},
+		{name: "JSON Lines Extension", filename: "cars.jsonl", expectedReader: ndjsonRecordReader{}},
+		{name: "XLSX Extension", filename: "cars.xlsx", expectedReader: xlsxRecordReader{}},
+		{name: "Format Over Extension", format: "ndjson", filename: "cars.json", expectedReader: ndjsonRecordReader{}},
+		{name: "Unsupported Extension", filename: "cars.xml", expectedError: true},
+		{name: "Unsupported Format", format: "xls", filename: "cars.csv", expectedError: true},
+		{name: "No Extension", filename: "cars", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reader, err := recordReaderFor(tc.format, tc.filename, CSVConfig{})
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.IsType(t, tc.expectedReader, reader)
+		})
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	testCases := []struct {
+		ref           string
+		expectedIndex int
+		expectedError bool
+	}{
+		{ref: "A1", expectedIndex: 0},
+		{ref: "AB3", expectedIndex: 27},
+		{ref: "XFD1", expectedIndex: 16383},
+		{ref: "XFE1", expectedError: true},
+		{ref: "ZZZZZZZ1", expectedError: true},
+		{ref: "1", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.ref, func(t *testing.T) {
+			index, err := columnIndex(tc.ref)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expectedIndex, index)
+			}
+		})
+	}
+}
+
+// testWorkbook builds an xlsx file laid out the way Excel saves them, with shared, rich and
+// inline strings, numbers, a skipped cell and a blank row
+func testWorkbook(t *testing.T) []byte {
+	files := map[string]string{
+		"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Cars" sheetId="1" r:id="rId3"/><sheet name="Notes" sheetId="2" r:id="rId1"/></sheets>
+</workbook>`,
+		"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet2.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/sharedStrings" Target="sharedStrings.xml"/>
+<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet1.xml"/>
+</Relationships>`,
+		"xl/sharedStrings.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="8" uniqueCount="8">
+<si><t>Company</t></si><si><t>Model</t></si><si><t>Horsepower</t></si><si><t>Model Year Range</t></si>
+<si><t>Ferrari</t></si><si><r><t>2020 - </t></r><r><rPr><b/></rPr><t>Present</t></r></si><si><t>Toyota</t></si><si><t>Corolla</t></si>
+</sst>`,
+		"xl/worksheets/sheet1.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>3</v></c></row>
+<row r="2"><c r="A2" t="s"><v>4</v></c><c r="B2" t="inlineStr"><is><t>Roma</t></is></c><c r="C2" t="inlineStr"><is><t>612 hp</t></is></c><c r="D2" t="s"><v>5</v></c></row>
+<row r="3"><c r="A3" s="1"/></row>
+<row r="4"><c r="A4" t="s"><v>6</v></c><c r="B4" t="s"><v>7</v></c><c r="D4"><v>2015</v></c></row>
+</sheetData>
+</worksheet>`,
+		"xl/worksheets/sheet2.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData/></worksheet>`,
+	}
+
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(strings.TrimSpace(content))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
@@ -1,36 +1,82 @@
 package main
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"time"
 )
 
 type CarRecord struct {
 	*Car
-	ModelYearRange    string    `csv:"Model Year Range"`
+	ModelYearRange    string    `csv:"Model Year Range" json:"modelYearRange"`
+	// where the record was found in its dataset
+	Line int `csv:"-" json:"-"`
+	// why the record couldn't be read properly (i.e. its row has too many fields), in which
+	// case it's rejected rather than cleaned
+	Err error `csv:"-" json:"-"`
 }
 
 type Car struct {
 	ID                int       `csv:"-" json:"id"`
 	Company           string    `csv:"Company" json:"company"`
+	ManufacturerID    *int      `csv:"-" json:"manufacturerId"`
 	Model             string    `csv:"Model" json:"model"`
 	Horsepower        string    `csv:"Horsepower" json:"horsepower"`
+	HorsepowerMin     *int      `csv:"-" json:"horsepowerMin"`
+	HorsepowerMax     *int      `csv:"-" json:"horsepowerMax"`
+	HorsepowerUnit    string    `csv:"-" json:"horsepowerUnit"`
+	HorsepowerRPMMin  *int      `csv:"-" json:"horsepowerRpmMin"`
+	HorsepowerRPMMax  *int      `csv:"-" json:"horsepowerRpmMax"`
 	Torque            string    `csv:"Torque" json:"torque"`
+	TorqueMinNm       *float64  `csv:"-" json:"torqueMinNm"`
+	TorqueMaxNm       *float64  `csv:"-" json:"torqueMaxNm"`
+	TorqueMinLbFt     *float64  `csv:"-" json:"torqueMinLbFt"`
+	TorqueMaxLbFt     *float64  `csv:"-" json:"torqueMaxLbFt"`
+	TorqueRPMMin      *int      `csv:"-" json:"torqueRpmMin"`
+	TorqueRPMMax      *int      `csv:"-" json:"torqueRpmMax"`
 	TransmissionType  string    `csv:"Transmission Type" json:"transmissionType"`
+	Transmissions     Transmissions `csv:"-" json:"transmissions"`
 	Drivetrain        string    `csv:"Drivetrain" json:"drivetrain"`
+	Drivetrains       []string  `csv:"-" json:"drivetrains"`
 	FuelEconomy       string    `csv:"Fuel Economy" json:"fuelEconomy"`
+	// city, highway and combined economy are stored in L/100km and expressed in FuelEconomyUnit
+	FuelEconomyCity       *float64 `csv:"-" json:"fuelEconomyCity"`
+	FuelEconomyHighway    *float64 `csv:"-" json:"fuelEconomyHighway"`
+	FuelEconomyCombined   *float64 `csv:"-" json:"fuelEconomyCombined"`
+	FuelEconomyUnit       string   `csv:"-" json:"fuelEconomyUnit"`
+	FuelEconomySourceUnit string   `csv:"-" json:"fuelEconomySourceUnit"`
 	NumberOfDoors     string    `csv:"Number of Doors" json:"numberOfDoors"`
+	Doors             []int64   `csv:"-" json:"doors"`
 	Price             string    `csv:"Price" json:"price"`
+	PriceCurrency     string    `csv:"-" json:"priceCurrency"`
+	PriceMin          *int64    `csv:"-" json:"priceMin"`
+	PriceMax          *int64    `csv:"-" json:"priceMax"`
+	PriceIsStarting   bool      `csv:"-" json:"priceIsStarting"`
 	StartYear         int       `csv:"-" json:"startYear"`
-	EndYear           int       `csv:"-" json:"endYear"`
+	// nil for cars that are still in production
+	EndYear           *int      `csv:"-" json:"endYear"`
+	InProduction      bool      `csv:"-" json:"inProduction"`
 	BodyType          string    `csv:"Body Type" json:"bodyType"`
+	BodyTypes         []string  `csv:"-" json:"bodyTypes"`
 	EngineType        string    `csv:"Engine Type" json:"engineType"`
+	// displacements are in litres
+	EngineDisplacements []float64 `csv:"-" json:"engineDisplacements"`
+	EngineLayouts       []string  `csv:"-" json:"engineLayouts"`
+	EngineAspirations   []string  `csv:"-" json:"engineAspirations"`
+	EngineFuels         []string  `csv:"-" json:"engineFuels"`
 	NumberofCylinders string    `csv:"Number of Cylinders" json:"numberOfCylinders"`
+	Cylinders         []int64   `csv:"-" json:"cylinders"`
+	// data-quality issues found while cleaning, served by the quality endpoint
+	Warnings          Issues    `csv:"-" json:"-"`
 	CreatedAt         time.Time `csv:"-" json:"createdAt"`
 }
 
 // NewCar creates a new Car instance with the given parameters
-func NewCar(company, model, horsepower, torque, transmissionType, drivetrain, fuelEconomy, numberOfDoors, price, bodyType, engineType, numberOfCylinders string, startYear, endYear int) *Car {
+func NewCar(company, model, horsepower, torque, transmissionType, drivetrain, fuelEconomy, numberOfDoors, price, bodyType, engineType, numberOfCylinders string, startYear int, endYear *int, inProduction bool) *Car {
 	return &Car{
 		Company:           company,
 		Model:             model,
@@ -43,6 +89,7 @@ func NewCar(company, model, horsepower, torque, transmissionType, drivetrain, fu
 		Price:             price,
 		StartYear:         startYear,
 		EndYear:           endYear,
+		InProduction:      inProduction,
 		BodyType:          bodyType,
 		EngineType:        engineType,
 		NumberofCylinders: numberOfCylinders,
@@ -54,6 +101,484 @@ func (c *Car) String() string {
 	return fmt.Sprintf("%s %s", c.Company, c.Model)
 }
 
+// naturalKey identifies a car across imports since IDs are only given by the database
+type naturalKey struct {
+	company   string
+	model     string
+	startYear int
+}
+
+func (c *Car) naturalKey() naturalKey {
+	return naturalKey{c.Company, c.Model, c.StartYear}
+}
+
+// Change is the old and new value of a field that changed between imports
+type Change struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// Diff returns the fields (by JSON name) of the other car that differ from this one's. The
+// ID, creation time and unit economy is expressed in aren't data of the car so they're ignored
+func (c *Car) Diff(other *Car) (map[string]Change, error) {
+	old, err := c.fields()
+	if err != nil {
+		return nil, err
+	}
+	updated, err := other.fields()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]Change{}
+	for name, value := range updated {
+		if !sameValue(old[name], value) {
+			changes[name] = Change{Old: old[name], New: value}
+		}
+	}
+	return changes, nil
+}
+
+// fields returns the car's fields as they're serialized, along with its warnings
+func (c *Car) fields() (map[string]any, error) {
+	b, err := json.Marshal(struct {
+		*Car
+		Warnings Issues `json:"qualityWarnings"`
+	}{c, c.Warnings})
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"id", "createdAt", "fuelEconomyUnit"} {
+		delete(fields, name)
+	}
+	return fields, nil
+}
+
+// sameValue compares unmarshalled JSON values. Nulls and empty lists are the same since the
+// database doesn't tell them apart, and numbers read back from numeric columns may be a
+// rounding error away from the ones they were stored as
+func sameValue(a, b any) bool {
+	if isEmptyValue(a) && isEmptyValue(b) {
+		return true
+	}
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		return ok && math.Abs(a-b) < 1e-9
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !sameValue(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			if !sameValue(value, b[key]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+func isEmptyValue(v any) bool {
+	list, ok := v.([]any)
+	return v == nil || ok && len(list) == 0
+}
+
+// Validate checks that the car's structured values are ones we support. It's meant for
+// cars given to us through the API; the dataset is cleaned leniently instead
+func (c *Car) Validate() error {
+	if c.InProduction && c.EndYear != nil {
+		return fmt.Errorf("a car in production can't have an end year")
+	}
+	if c.EndYear != nil && *c.EndYear < c.StartYear {
+		return fmt.Errorf("end year %d is before start year %d", *c.EndYear, c.StartYear)
+	}
+
+	for _, drivetrain := range c.Drivetrains {
+		if !containsString(drivetrains, drivetrain) {
+			return fmt.Errorf("invalid drivetrain %q, must be one of %s", drivetrain, strings.Join(drivetrains, ", "))
+		}
+	}
+	if c.Drivetrain != "" && len(c.Drivetrains) == 0 {
+		return fmt.Errorf("unrecognized drivetrain %q", c.Drivetrain)
+	}
+
+	for _, transmission := range c.Transmissions {
+		if !containsString(transmissionKinds, transmission.Kind) {
+			return fmt.Errorf("invalid transmission kind %q, must be one of %s", transmission.Kind, strings.Join(transmissionKinds, ", "))
+		}
+		if transmission.Gears != nil && *transmission.Gears < 1 {
+			return fmt.Errorf("invalid number of gears %d for %s transmission", *transmission.Gears, transmission.Kind)
+		}
+	}
+	if c.TransmissionType != "" && len(c.Transmissions) == 0 {
+		return fmt.Errorf("unrecognized transmission %q", c.TransmissionType)
+	}
+
+	for _, bodyType := range c.BodyTypes {
+		if bodyStyle(bodyType) == nil {
+			return fmt.Errorf("invalid body type %q, must be one of %s", bodyType, strings.Join(bodyStyleSlugs(), ", "))
+		}
+	}
+	if c.BodyType != "" && len(c.BodyTypes) == 0 {
+		return fmt.Errorf("unrecognized body type %q", c.BodyType)
+	}
+	return nil
+}
+
+// YearRange is the span of model years a car was made for
+type YearRange struct {
+	StartYear int
+	// nil for cars that are still in production
+	EndYear      *int
+	InProduction bool
+}
+
+// Contains reports whether the car was in production during the given year
+func (y YearRange) Contains(year int) bool {
+	return y.StartYear <= year && (y.InProduction || y.EndYear != nil && *y.EndYear >= year)
+}
+
+const (
+	DrivetrainFWD = "FWD"
+	DrivetrainRWD = "RWD"
+	DrivetrainAWD = "AWD"
+	Drivetrain4WD = "4WD"
+)
+
+// drivetrains is the fixed set of drivetrains a car can be offered with
+var drivetrains = []string{DrivetrainFWD, DrivetrainRWD, DrivetrainAWD, Drivetrain4WD}
+
+// BodyStyle is a body style of the controlled vocabulary cars' body types are cleaned into
+type BodyStyle struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+	// other (lowercased) names the style goes by in the dataset
+	Synonyms []string `json:"synonyms"`
+}
+
+// bodyStyles is the controlled vocabulary of body styles
+var bodyStyles = []BodyStyle{
+	{Slug: "sedan", Name: "Sedan", Synonyms: []string{"saloon"}},
+	{Slug: "coupe", Name: "Coupe", Synonyms: []string{"coupé"}},
+	{Slug: "convertible", Name: "Convertible", Synonyms: []string{"cabriolet", "cabrio"}},
+	{Slug: "roadster", Name: "Roadster", Synonyms: []string{"spyder", "spider"}},
+	{Slug: "hatchback", Name: "Hatchback", Synonyms: []string{"hatch"}},
+	{Slug: "wagon", Name: "Wagon", Synonyms: []string{"estate", "station wagon", "shooting brake"}},
+	{Slug: "suv", Name: "SUV", Synonyms: []string{"sport utility vehicle"}},
+	{Slug: "crossover", Name: "Crossover", Synonyms: []string{"cuv"}},
+	{Slug: "pickup", Name: "Pickup", Synonyms: []string{"pickup truck", "pick-up", "truck"}},
+	{Slug: "van", Name: "Van"},
+	{Slug: "minivan", Name: "Minivan", Synonyms: []string{"mpv", "people carrier"}},
+	{Slug: "sports-car", Name: "Sports car", Synonyms: []string{"supercar", "hypercar", "track car"}},
+	{Slug: "grand-tourer", Name: "Grand tourer", Synonyms: []string{"gt"}},
+	{Slug: "city-car", Name: "City car", Synonyms: []string{"microcar"}},
+}
+
+// bodyStyle returns the body style with the given slug, name or synonym (ignoring case)
+func bodyStyle(name string) *BodyStyle {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i, style := range bodyStyles {
+		if name == style.Slug || name == strings.ToLower(style.Name) || containsString(style.Synonyms, name) {
+			return &bodyStyles[i]
+		}
+	}
+	return nil
+}
+
+func bodyStyleSlugs() []string {
+	slugs := make([]string, len(bodyStyles))
+	for i, style := range bodyStyles {
+		slugs[i] = style.Slug
+	}
+	return slugs
+}
+
+// BodyTypeCount is a body style along with the number of cars having it
+type BodyTypeCount struct {
+	BodyStyle
+	Count int `json:"count"`
+}
+
+const (
+	fuelEconomyUnitL100km = "L/100km"
+	fuelEconomyUnitMPG    = "mpg"
+	fuelEconomyUnitMPGe   = "MPGe"
+	fuelEconomyUnitKmpl   = "kmpl"
+
+	// litres per 100km = litresPer100kmMPG / miles per (US) gallon
+	litresPer100kmMPG = 235.214583
+)
+
+// ParseFuelEconomyUnit returns the fuel economy unit for its (case-insensitive) name.
+// An error is returned for units that cars can't be converted to
+func ParseFuelEconomyUnit(unit string) (string, error) {
+	switch strings.ToLower(strings.ReplaceAll(unit, " ", "")) {
+	case "l/100km", "l100km":
+		return fuelEconomyUnitL100km, nil
+	case "mpg":
+		return fuelEconomyUnitMPG, nil
+	case "kmpl", "km/l":
+		return fuelEconomyUnitKmpl, nil
+	default:
+		return "", fmt.Errorf("unsupported fuel economy unit: %s", unit)
+	}
+}
+
+// ConvertFuelEconomy converts the car's city, highway and combined economy to the given unit
+func (c *Car) ConvertFuelEconomy(unit string) {
+	if c.FuelEconomyUnit == "" || c.FuelEconomyUnit == unit {
+		return
+	}
+	for _, value := range []*float64{c.FuelEconomyCity, c.FuelEconomyHighway, c.FuelEconomyCombined} {
+		if value != nil {
+			litres := convertFuelEconomy(*value, c.FuelEconomyUnit)
+			*value = math.Round(convertFuelEconomy(litres, unit)*10) / 10
+		}
+	}
+	c.FuelEconomyUnit = unit
+}
+
+// convertFuelEconomy converts a value from L/100km to the given unit or from the given unit
+// to L/100km. Both directions are the same since each unit is inversely proportional to L/100km
+func convertFuelEconomy(value float64, unit string) float64 {
+	if value == 0 {
+		return 0
+	}
+	switch unit {
+	case fuelEconomyUnitMPG, fuelEconomyUnitMPGe:
+		return litresPer100kmMPG / value
+	case fuelEconomyUnitKmpl:
+		return 100 / value
+	default:
+		return value
+	}
+}
+
+const (
+	TransmissionManual    = "manual"
+	TransmissionAutomatic = "automatic"
+	TransmissionDCT       = "DCT"
+	TransmissionCVT       = "CVT"
+)
+
+const (
+	EngineLayoutV      = "V"
+	EngineLayoutInline = "inline"
+	EngineLayoutFlat   = "flat"
+	EngineLayoutW      = "W"
+
+	AspirationNatural      = "NA"
+	AspirationTurbo        = "turbo"
+	AspirationSupercharged = "supercharged"
+
+	FuelPetrol   = "petrol"
+	FuelDiesel   = "diesel"
+	FuelHybrid   = "hybrid"
+	FuelElectric = "electric"
+)
+
+// transmissionKinds is the fixed set of kinds a transmission can be
+var transmissionKinds = []string{TransmissionManual, TransmissionAutomatic, TransmissionDCT, TransmissionCVT}
+
+// Transmission is one of the transmission options a car is offered with
+type Transmission struct {
+	Kind  string `json:"kind"`
+	Gears *int   `json:"gears"`
+	// branded name of the transmission (i.e. PDK, DSG or Tiptronic S) if it has one
+	Label string `json:"label"`
+}
+
+// String describes the transmission the way the dataset does (i.e. "7-speed PDK")
+func (t Transmission) String() string {
+	name := t.Kind
+	if t.Label != "" {
+		name = t.Label
+	}
+	if t.Gears != nil {
+		return fmt.Sprintf("%d-speed %s", *t.Gears, name)
+	}
+	return name
+}
+
+// Transmissions is stored as a JSON array in the database
+type Transmissions []Transmission
+
+// Value implements driver.Valuer. The JSON is returned as a string rather
+// than bytes so that it isn't taken as bytea
+func (t Transmissions) Value() (driver.Value, error) {
+	if t == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(t)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner
+func (t *Transmissions) Scan(src any) error {
+	return scanJSON(src, t)
+}
+
+// scanJSON unmarshals a JSON column into dest, leaving it untouched for NULLs
+func scanJSON(src any, dest any) error {
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(src, dest)
+	case string:
+		return json.Unmarshal([]byte(src), dest)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, dest)
+	}
+}
+
+// Issue is a data-quality problem found in one of a car's columns
+type Issue struct {
+	Column  string `json:"column"`
+	Message string `json:"message"`
+}
+
+// Issues is stored as a JSON array in the database. It's also the error returned
+// when a car couldn't be cleaned
+type Issues []Issue
+
+func (i Issues) Error() string {
+	messages := make([]string, len(i))
+	for j, issue := range i {
+		messages[j] = issue.Column + ": " + issue.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Value implements driver.Valuer. See Transmissions.Value
+func (i Issues) Value() (driver.Value, error) {
+	if i == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(i)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner
+func (i *Issues) Scan(src any) error {
+	return scanJSON(src, i)
+}
+
+// ImportSummary counts what happened to the rows of an imported dataset, along with what
+// changed about the cars that were updated
+type ImportSummary struct {
+	Inserted  int         `json:"inserted"`
+	Updated   int         `json:"updated"`
+	Unchanged int         `json:"unchanged"`
+	Rejected  int         `json:"rejected"`
+	Updates   []CarUpdate `json:"updates,omitempty"`
+	// dataset version created by the import
+	Version int `json:"version,omitempty"`
+}
+
+// DatasetVersion is the state the cars were in after an import or a write through the API
+// (with "api" as the source), which can still be queried
+type DatasetVersion struct {
+	ID        int       `json:"id"`
+	Source    string    `json:"source"`
+	Cars      int       `json:"cars"`
+	Inserted  int       `json:"inserted"`
+	Updated   int       `json:"updated"`
+	Unchanged int       `json:"unchanged"`
+	Rejected  int       `json:"rejected"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CarUpdate is a car changed by an import and the fields (by JSON name) that changed
+type CarUpdate struct {
+	ID     int      `json:"id"`
+	Car    string   `json:"car"`
+	Fields []string `json:"fields"`
+}
+
+// addUpdate counts the car as updated with the given changes
+func (s *ImportSummary) addUpdate(car *Car, changes map[string]Change) {
+	fields := make([]string, 0, len(changes))
+	for field := range changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	s.Updated++
+	s.Updates = append(s.Updates, CarUpdate{ID: car.ID, Car: car.String(), Fields: fields})
+}
+
+// RejectedRow is a row of a dataset that couldn't be cleaned or stored. It's kept so that it
+// can be fixed and retried
+type RejectedRow struct {
+	ID         int    `json:"id"`
+	SourceFile string `json:"sourceFile"`
+	// line of the row in the file, the header being line 1
+	LineNumber int `json:"lineNumber"`
+	// the CarRecord as it was read, before it was cleaned
+	Record    json.RawMessage `json:"record" swaggertype:"object"`
+	Error     string          `json:"error"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// ImportReport is the outcome of cleaning every row of a dataset
+type ImportReport struct {
+	Filename string `json:"filename"`
+	Rows     int    `json:"rows"`
+	Valid    int    `json:"valid"`
+	Invalid  int    `json:"invalid"`
+	Warnings int    `json:"warnings"`
+	// rows with errors or warnings, in file order
+	Issues []RowReport `json:"issues"`
+}
+
+// RowReport lists the issues found in a row of a dataset
+type RowReport struct {
+	// line of the row in the file, the header being line 1
+	Line     int    `json:"line"`
+	Car      string `json:"car"`
+	Errors   Issues `json:"errors,omitempty"`
+	Warnings Issues `json:"warnings,omitempty"`
+}
+
+// CarQuality reports how a car's counts were cleaned and the issues found along the way
+type CarQuality struct {
+	ID                int     `json:"id"`
+	NumberOfDoors     string  `json:"numberOfDoors"`
+	Doors             []int64 `json:"doors"`
+	NumberofCylinders string  `json:"numberOfCylinders"`
+	Cylinders         []int64 `json:"cylinders"`
+	Warnings          Issues  `json:"warnings"`
+}
+
+// Manufacturer is the canonical company behind one or more of the dataset's Company values
+type Manufacturer struct {
+	ID          int      `json:"id" mapstructure:"-"`
+	Name        string   `json:"name" mapstructure:"name"`
+	Aliases     []string `json:"aliases" mapstructure:"aliases"`
+	ParentGroup string   `json:"parentGroup" mapstructure:"parent_group"`
+	Country     string   `json:"country" mapstructure:"country"`
+}
+
 type Credentials struct {
 	Username string
 	Password []byte
@@ -62,4 +587,112 @@ type Credentials struct {
 type Pagination struct {
 	Offset uint
 	Limit uint
-}
\ No newline at end of file
+	// the order cars are listed in, after which they're ordered by ID
+	Sort []SortField
+	// when given, cars are listed from the one the cursor points at rather than from the offset
+	Cursor *Cursor
+}
+
+// CarsEnvelope wraps a page of cars along with where it is in the listing
+type CarsEnvelope struct {
+	Data       []*Car `json:"data"`
+	Page       int    `json:"page"`
+	PerPage    int    `json:"perPage"`
+	TotalPages int    `json:"totalPages"`
+	Total      int    `json:"total"`
+}
+
+// SortField is a field cars are sorted by, given as sort=-horsepower,company
+type SortField struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc,omitempty"`
+}
+
+// sortableFields are the fields cars can be sorted by. Price, horsepower and torque sort by
+// the low end of their range
+var sortableFields = []string{"id", "company", "model", "year", "price", "horsepower", "torque", "createdAt"}
+
+// sortKeys returns the fields cars are actually sorted by: the given ones, then the ID to break
+// ties unless it's already been sorted by, since no field comes after it
+func sortKeys(fields []SortField) []SortField {
+	for i, field := range fields {
+		if field.Field == "id" {
+			return fields[:i+1]
+		}
+	}
+	return append(append([]SortField{}, fields...), SortField{Field: "id"})
+}
+
+// sortValue returns the value of the car for one of the sortableFields, nil when it's missing
+func (c *Car) sortValue(field string) any {
+	switch field {
+	case "id":
+		return c.ID
+	case "company":
+		return c.Company
+	case "model":
+		return c.Model
+	case "year":
+		return c.StartYear
+	case "price":
+		if c.PriceMin != nil {
+			return *c.PriceMin
+		}
+	case "horsepower":
+		if c.HorsepowerMin != nil {
+			return *c.HorsepowerMin
+		}
+	case "torque":
+		if c.TorqueMinNm != nil {
+			return *c.TorqueMinNm
+		}
+	case "createdAt":
+		return c.CreatedAt
+	}
+	return nil
+}
+
+// CarFilter narrows down the cars returned by GetCars. Zero values don't filter
+type CarFilter struct {
+	// matches cars of this company, ignoring case
+	Company string
+	// matches cars whose model contains this, ignoring case
+	Model string
+	// matches cars having this body style (by slug)
+	BodyType string
+	// matches cars offered with this drivetrain
+	Drivetrain string
+	// matches cars offered with a transmission of this kind
+	Transmission string
+	// matches cars made by this manufacturer
+	ManufacturerID int
+	// matches cars in production during this year
+	Year int
+	// matches cars priced in this currency (an ISO 4217 code)
+	Currency string
+	// matches the cars as they were at this dataset version rather than as they are now
+	Version int
+	// matches cars whose numeric fields compare to the given values
+	Ranges []RangeFilter
+}
+
+// Operators of range filters, given as price[lte]=50000
+const (
+	rangeEq  = "eq"
+	rangeLt  = "lt"
+	rangeLte = "lte"
+	rangeGt  = "gt"
+	rangeGte = "gte"
+)
+
+var rangeOperators = []string{rangeEq, rangeLt, rangeLte, rangeGt, rangeGte}
+
+// RangeFilter compares a numeric field of cars (price, horsepower, torque or year) to a value.
+// Since those fields span a range, from the cheapest trim to the most expensive one say, a car
+// matches when any value of its range does. Prices are compared in major units (dollars, not
+// cents) of the filter's currency
+type RangeFilter struct {
+	Field string
+	Op    string
+	Value float64
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+// defaultWatchInterval is how often the dataset file is checked when no interval is configured
+const defaultWatchInterval = 10 * time.Second
+
+// fileState is what's compared between polls to tell that a file was written to
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func (f fileState) equal(other fileState) bool {
+	return f.modTime.Equal(other.modTime) && f.size == other.size
+}
+
+// watchDataset checks the file every interval and calls reload once it has changed. Polling
+// is used rather than file system events since those get lost when editors replace the file
+// or it's on a mounted volume. A change is only reloaded once it has settled, the file being
+// the same on two checks in a row, so that a file still being copied isn't read half way.
+// Files that were only touched, with the same content, aren't reloaded. Stops once the
+// context is done
+func watchDataset(ctx context.Context, path string, interval time.Duration, reload func()) {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	// the file is compared against how it was when watching started
+	state, _ := statFile(path)
+	hash, _ := hashFile(path)
+	var pending *fileState
+
+	log.Info("Watching dataset for changes", "filename", path, "interval", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current, err := statFile(path)
+		if err != nil {
+			// the file can be gone for a moment while it's being replaced
+			log.Debug("Unable to check dataset", "filename", path, "err", err)
+			continue
+		}
+		if current.equal(state) {
+			pending = nil
+			continue
+		}
+		if pending == nil || !current.equal(*pending) {
+			pending = &current
+			continue
+		}
+		pending = nil
+
+		newHash, err := hashFile(path)
+		if err != nil {
+			log.Error("Unable to read changed dataset", "filename", path, "err", err)
+			continue
+		}
+		state = current
+		if newHash == hash {
+			log.Debug("Dataset was touched but its content is the same", "filename", path)
+			continue
+		}
+		hash = newHash
+
+		log.Info("Dataset changed, reloading", "filename", path)
+		reload()
+	}
+}
+
+func statFile(path string) (fileState, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchDataset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cars.csv")
+	if err := os.WriteFile(path, []byte("Company,Model\nToyota,Corolla\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloads := make(chan struct{}, 10)
+	go watchDataset(ctx, path, 10*time.Millisecond, func() { reloads <- struct{}{} })
+
+	testCases := []struct {
+		name           string
+		change         func() error
+		expectedReload bool
+	}{
+		{
+			name:           "Unchanged",
+			change:         func() error { return nil },
+			expectedReload: false,
+		},
+		{
+			name: "Changed",
+			change: func() error {
+				return os.WriteFile(path, []byte("Company,Model\nToyota,Corolla\nFerrari,Roma\n"), 0644)
+			},
+			expectedReload: true,
+		},
+		{
+			name: "Touched",
+			change: func() error {
+				later := time.Now().Add(time.Minute)
+				return os.Chtimes(path, later, later)
+			},
+			expectedReload: false,
+		},
+		{
+			name: "Replaced",
+			change: func() error {
+				replacement := path + ".tmp"
+				if err := os.WriteFile(replacement, []byte("Company,Model\nFerrari,Roma\n"), 0644); err != nil {
+					return err
+				}
+				return os.Rename(replacement, path)
+			},
+			expectedReload: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if !assert.NoError(t, tc.change()) {
+				return
+			}
+
+			select {
+			case <-reloads:
+				assert.True(t, tc.expectedReload, "reloaded")
+			case <-time.After(200 * time.Millisecond):
+				assert.False(t, tc.expectedReload, "not reloaded")
+			}
+		})
+	}
+}